@@ -0,0 +1,323 @@
+package directory_snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/debanandanayak/tester-utils/executable"
+	"github.com/debanandanayak/tester-utils/logger"
+)
+
+// Entry describes a file, directory or symlink in a Snapshot.
+type Entry struct {
+	// Path is relative to the snapshot's root, and always uses forward slashes. Example: ".git/objects/ab/cdef"
+	Path string
+
+	Mode fs.FileMode
+	Size int64
+
+	// ContentHash is the hex-encoded SHA-256 of the file's contents. For symlinks it is the hash of the link target,
+	// for directories it is empty.
+	ContentHash string
+}
+
+func (e Entry) IsDir() bool {
+	return e.Mode.IsDir()
+}
+
+// describe returns a short description of the entry. Example: "file, 42 bytes"
+func (e Entry) describe() string {
+	switch {
+	case e.Mode.IsDir():
+		return "directory"
+	case e.Mode&fs.ModeSymlink != 0:
+		return "symlink"
+	default:
+		return fmt.Sprintf("file, %d bytes", e.Size)
+	}
+}
+
+// Snapshot holds the state of every entry in a directory tree at a point in time.
+type Snapshot struct {
+	RootDir string
+
+	// Entries is keyed by Entry.Path. The root directory itself isn't included.
+	Entries map[string]Entry
+}
+
+// Take walks rootDir and records the path, mode, size & content hash of everything in it.
+func Take(rootDir string) (Snapshot, error) {
+	snapshot := Snapshot{RootDir: rootDir, Entries: map[string]Entry{}}
+
+	err := filepath.WalkDir(rootDir, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == rootDir {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return err
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+
+		entry := Entry{
+			Path: filepath.ToSlash(relativePath),
+			Mode: info.Mode(),
+		}
+
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			entry.Size = int64(len(target))
+			entry.ContentHash = hashBytes([]byte(target))
+		case info.Mode().IsRegular():
+			entry.Size = info.Size()
+			entry.ContentHash, err = hashFile(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		snapshot.Entries[entry.Path] = entry
+
+		return nil
+	})
+
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to take snapshot of %s: %w", rootDir, err)
+	}
+
+	return snapshot, nil
+}
+
+// Modification is an entry that exists in both snapshots, but with a different mode, size or content.
+type Modification struct {
+	Before Entry
+	After  Entry
+}
+
+// describe returns a short description of what changed. Example: "content changed, 10 -> 12 bytes"
+func (m Modification) describe() string {
+	changes := []string{}
+
+	if m.Before.Mode != m.After.Mode {
+		changes = append(changes, fmt.Sprintf("mode changed, %s -> %s", m.Before.Mode, m.After.Mode))
+	}
+
+	if m.Before.ContentHash != m.After.ContentHash {
+		if m.Before.Size != m.After.Size {
+			changes = append(changes, fmt.Sprintf("content changed, %d -> %d bytes", m.Before.Size, m.After.Size))
+		} else {
+			changes = append(changes, "content changed")
+		}
+	}
+
+	return strings.Join(changes, ", ")
+}
+
+// Diff is the difference between two snapshots. Each list is sorted by path.
+type Diff struct {
+	Created  []Entry
+	Modified []Modification
+	Deleted  []Entry
+}
+
+// Compare returns what changed between before and after.
+func Compare(before Snapshot, after Snapshot) Diff {
+	diff := Diff{Created: []Entry{}, Modified: []Modification{}, Deleted: []Entry{}}
+
+	for _, path := range sortedPaths(after.Entries) {
+		afterEntry := after.Entries[path]
+		beforeEntry, existedBefore := before.Entries[path]
+
+		if !existedBefore {
+			diff.Created = append(diff.Created, afterEntry)
+		} else if beforeEntry.Mode != afterEntry.Mode || beforeEntry.ContentHash != afterEntry.ContentHash {
+			diff.Modified = append(diff.Modified, Modification{Before: beforeEntry, After: afterEntry})
+		}
+	}
+
+	for _, path := range sortedPaths(before.Entries) {
+		if _, existsAfter := after.Entries[path]; !existsAfter {
+			diff.Deleted = append(diff.Deleted, before.Entries[path])
+		}
+	}
+
+	return diff
+}
+
+// RunAndCompare takes a snapshot of dir, runs the executable with args, and returns the result along with what the
+// program changed in dir.
+func RunAndCompare(e *executable.Executable, dir string, args ...string) (executable.ExecutableResult, Diff, error) {
+	before, err := Take(dir)
+	if err != nil {
+		return executable.ExecutableResult{}, Diff{}, err
+	}
+
+	result, err := e.Run(args...)
+	if err != nil {
+		return result, Diff{}, err
+	}
+
+	after, err := Take(dir)
+	if err != nil {
+		return result, Diff{}, err
+	}
+
+	return result, Compare(before, after), nil
+}
+
+func (d Diff) IsEmpty() bool {
+	return len(d.Created) == 0 && len(d.Modified) == 0 && len(d.Deleted) == 0
+}
+
+// ChangedPaths returns the paths of all created, modified & deleted entries, sorted.
+func (d Diff) ChangedPaths() []string {
+	paths := []string{}
+
+	for _, entry := range d.Created {
+		paths = append(paths, entry.Path)
+	}
+
+	for _, modification := range d.Modified {
+		paths = append(paths, modification.After.Path)
+	}
+
+	for _, entry := range d.Deleted {
+		paths = append(paths, entry.Path)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// Filter returns a Diff that only includes entries for which shouldInclude returns true.
+func (d Diff) Filter(shouldInclude func(path string) bool) Diff {
+	filtered := Diff{Created: []Entry{}, Modified: []Modification{}, Deleted: []Entry{}}
+
+	for _, entry := range d.Created {
+		if shouldInclude(entry.Path) {
+			filtered.Created = append(filtered.Created, entry)
+		}
+	}
+
+	for _, modification := range d.Modified {
+		if shouldInclude(modification.After.Path) {
+			filtered.Modified = append(filtered.Modified, modification)
+		}
+	}
+
+	for _, entry := range d.Deleted {
+		if shouldInclude(entry.Path) {
+			filtered.Deleted = append(filtered.Deleted, entry)
+		}
+	}
+
+	return filtered
+}
+
+// WithinDir returns a Diff that only includes entries inside dir (a path relative to the snapshot's root). Example:
+//
+//	diff.WithinDir(".git/objects")
+func (d Diff) WithinDir(dir string) Diff {
+	prefix := strings.TrimSuffix(filepath.ToSlash(dir), "/") + "/"
+
+	return d.Filter(func(path string) bool {
+		return strings.HasPrefix(path, prefix)
+	})
+}
+
+// OutsideDir returns a Diff that only includes entries that aren't inside dir (or dir itself). Useful for checking that
+// a program didn't write anywhere else:
+//
+//	if otherChanges := diff.OutsideDir(".git"); !otherChanges.IsEmpty() {
+//	    ...
+//	}
+func (d Diff) OutsideDir(dir string) Diff {
+	dir = strings.TrimSuffix(filepath.ToSlash(dir), "/")
+
+	return d.Filter(func(path string) bool {
+		return path != dir && !strings.HasPrefix(path, dir+"/")
+	})
+}
+
+// FormatLines returns lines to be presented to the user. Example: "created: .git/HEAD (file, 21 bytes)"
+func (d Diff) FormatLines() []string {
+	if d.IsEmpty() {
+		return []string{"No changes"}
+	}
+
+	lines := []string{}
+
+	for _, entry := range d.Created {
+		lines = append(lines, fmt.Sprintf("created: %s (%s)", entry.Path, entry.describe()))
+	}
+
+	for _, modification := range d.Modified {
+		lines = append(lines, fmt.Sprintf("modified: %s (%s)", modification.After.Path, modification.describe()))
+	}
+
+	for _, entry := range d.Deleted {
+		lines = append(lines, fmt.Sprintf("deleted: %s (%s)", entry.Path, entry.describe()))
+	}
+
+	return lines
+}
+
+// Log logs the diff, one line per changed entry.
+func (d Diff) Log(logger *logger.Logger) {
+	for _, line := range d.FormatLines() {
+		logger.Infoln(line)
+	}
+}
+
+func sortedPaths(entries map[string]Entry) []string {
+	paths := make([]string, 0, len(entries))
+	for path := range entries {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashBytes(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,68 @@
+package directory_snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/debanandanayak/tester-utils/executable"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, path string, contents string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+}
+
+func TestTake(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "hello")
+	writeFile(t, filepath.Join(dir, "nested", "b.txt"), "")
+	assert.NoError(t, os.Symlink("a.txt", filepath.Join(dir, "link")))
+
+	snapshot, err := Take(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "link", "nested", "nested/b.txt"}, sortedPaths(snapshot.Entries))
+
+	assert.Equal(t, int64(5), snapshot.Entries["a.txt"].Size)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", snapshot.Entries["a.txt"].ContentHash)
+	assert.True(t, snapshot.Entries["nested"].IsDir())
+	assert.Equal(t, "", snapshot.Entries["nested"].ContentHash)
+
+	_, err = Take(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestRunAndCompare(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "unchanged.txt"), "same")
+	writeFile(t, filepath.Join(dir, "modified.txt"), "before")
+	writeFile(t, filepath.Join(dir, "deleted.txt"), "bye")
+	writeFile(t, filepath.Join(dir, "run.sh"), "")
+
+	e := executable.NewExecutable("bash")
+	e.WorkingDir = dir
+
+	result, diff, err := RunAndCompare(e, dir, "-c", "mkdir -p .git/objects && echo ref > .git/HEAD && echo after! > modified.txt && rm deleted.txt && chmod +x run.sh")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+
+	assert.Equal(t, []string{".git", ".git/HEAD", ".git/objects", "deleted.txt", "modified.txt", "run.sh"}, diff.ChangedPaths())
+	assert.Equal(t, []string{
+		"created: .git (directory)",
+		"created: .git/HEAD (file, 4 bytes)",
+		"created: .git/objects (directory)",
+		"modified: modified.txt (content changed, 6 -> 7 bytes)",
+		"modified: run.sh (mode changed, -rw-r--r-- -> -rwxr-xr-x)",
+		"deleted: deleted.txt (file, 3 bytes)",
+	}, diff.FormatLines())
+
+	assert.Equal(t, []string{".git/HEAD", ".git/objects"}, diff.WithinDir(".git").ChangedPaths())
+	assert.Equal(t, []string{"deleted.txt", "modified.txt", "run.sh"}, diff.OutsideDir(".git").ChangedPaths())
+
+	// Nothing changed
+	_, diff, err = RunAndCompare(e, dir, "-c", "cat modified.txt")
+	assert.NoError(t, err)
+	assert.True(t, diff.IsEmpty())
+	assert.Equal(t, []string{"No changes"}, diff.FormatLines())
+}
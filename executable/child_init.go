@@ -0,0 +1,110 @@
+package executable
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// childInitEnvVar is set when the tester binary is re-executed to prepare the environment a program runs in (like
+// bringing up the loopback interface in a new network namespace), before executing the program. The value is the
+// JSON-encoded childInitConfig.
+const childInitEnvVar = "TESTER_UTILS_CHILD_INIT"
+
+// These are the steps the re-executed tester binary can fail at, see childInitError.
+const (
+	childInitStepSetup          = "setup"
+	childInitStepNetwork        = "network"
+	childInitStepResourceLimits = "resource_limits"
+	childInitStepExecute        = "execute"
+)
+
+// isInitCalled is set by Init, features that re-execute the tester binary aren't available without it.
+var isInitCalled atomic.Bool
+
+// Init must be called at the very start of main() in testers, before anything else runs:
+//
+//	func main() {
+//	    executable.Init()
+//
+//	    os.Exit(tester_utils.RunCLI(getEnv(), definition))
+//	}
+//
+// Some features (like ShouldIsolateNetwork and ResourceLimits) are set up by re-executing the tester binary, which then
+// executes the program. Init does that setup when the binary was re-executed, and never returns in that case, like
+// reexec.Init() in Docker. Everything that runs before Init (like init functions of packages) also runs in the
+// re-executed binary, so it must not have side effects like printing or creating files.
+//
+// If Init wasn't called, these features aren't available. Network isolation falls back to running the program without
+// it, with a warning. Starting a program with MaxCPUTimeInSeconds or MaxOpenFiles set fails.
+func Init() {
+	isInitCalled.Store(true)
+
+	if configJSON, ok := os.LookupEnv(childInitEnvVar); ok {
+		runChildInit(configJSON)
+	}
+}
+
+// childInitConfig is what the re-executed tester binary sets up before executing the program.
+type childInitConfig struct {
+	ShouldBringUpLoopback bool           `json:"should_bring_up_loopback"`
+	ResourceLimits        ResourceLimits `json:"resource_limits"`
+}
+
+// childInitError is returned when starting a program if the re-executed tester binary failed to set up the
+// environment, or to execute the program.
+type childInitError struct {
+	Step    string `json:"step"`
+	Message string `json:"message"`
+}
+
+func (e *childInitError) Error() string {
+	return e.Message
+}
+
+// childInitStatus is a pipe that the re-executed tester binary reports failures on. The write end is closed when the
+// program is executed, so reading from the pipe returns EOF once the program is running.
+type childInitStatus struct {
+	reader *os.File
+	writer *os.File
+}
+
+func newChildInitStatus() (*childInitStatus, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	return &childInitStatus{reader: reader, writer: writer}, nil
+}
+
+// wait must be called after the command is started. It blocks until the program has been executed, and returns a
+// *childInitError if the re-executed tester binary failed before that.
+func (s *childInitStatus) wait() error {
+	s.writer.Close() // Our copy, otherwise reading would never return EOF
+	defer s.reader.Close()
+
+	status, err := io.ReadAll(s.reader)
+	if err != nil {
+		return fmt.Errorf("failed to read status of program setup: %w", err)
+	}
+
+	if len(status) == 0 {
+		return nil
+	}
+
+	childInitErr := &childInitError{}
+	if err := json.Unmarshal(status, childInitErr); err != nil {
+		return fmt.Errorf("failed to parse status of program setup: %w", err)
+	}
+
+	return childInitErr
+}
+
+// close must be called instead of wait if the command couldn't be started.
+func (s *childInitStatus) close() {
+	s.writer.Close()
+	s.reader.Close()
+}
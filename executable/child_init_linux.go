@@ -0,0 +1,96 @@
+package executable
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// childInitStatusFd is where the re-executed tester binary finds the write end of the childInitStatus pipe, it is the
+// first of exec.Cmd.ExtraFiles.
+const childInitStatusFd = 3
+
+// childInitFailedExitCode is what the re-executed tester binary exits with if it fails, like a shell does when a
+// command can't be executed.
+const childInitFailedExitCode = 127
+
+// wrapWithChildInit makes cmd run the tester binary itself, which sets up what config asks for and then executes the
+// program. The returned status must be waited for once cmd is started.
+func wrapWithChildInit(cmd *exec.Cmd, config childInitConfig) (*childInitStatus, error) {
+	if !isInitCalled.Load() {
+		return nil, errors.New("executable.Init() wasn't called at the start of main()")
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := newChildInitStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+
+	cmd.Args = append([]string{"/proc/self/exe", cmd.Path}, cmd.Args...)
+	cmd.Path = "/proc/self/exe"
+	cmd.Env = append(env, childInitEnvVar+"="+string(configJSON))
+	cmd.ExtraFiles = []*os.File{status.writer}
+
+	return status, nil
+}
+
+// runChildInit runs in the re-executed tester binary, see wrapWithChildInit. It never returns.
+//
+// os.Args is ["/proc/self/exe", <program path>, <program argv...>]
+func runChildInit(configJSON string) {
+	// The program shouldn't inherit the status pipe. Executing the program closes it, which tells the tester that the
+	// program is running.
+	unix.CloseOnExec(childInitStatusFd)
+
+	config := childInitConfig{}
+	if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+		reportChildInitFailure(childInitStepSetup, fmt.Errorf("invalid %s: %w", childInitEnvVar, err))
+	}
+
+	if config.ShouldBringUpLoopback {
+		if err := bringUpLoopbackInterface(); err != nil {
+			reportChildInitFailure(childInitStepNetwork, fmt.Errorf("failed to bring up the loopback interface: %w", err))
+		}
+	}
+
+	// The program shouldn't inherit capabilities needed for the setup, like CAP_NET_ADMIN
+	unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0)
+
+	env := []string{}
+	for _, keyValue := range os.Environ() {
+		if !strings.HasPrefix(keyValue, childInitEnvVar+"=") {
+			env = append(env, keyValue)
+		}
+	}
+
+	// Applied last, so that they don't limit the setup itself
+	if err := applyResourceLimits(config.ResourceLimits); err != nil {
+		reportChildInitFailure(childInitStepResourceLimits, fmt.Errorf("failed to apply resource limits: %w", err))
+	}
+
+	err := syscall.Exec(os.Args[1], os.Args[2:], env)
+	reportChildInitFailure(childInitStepExecute, fmt.Errorf("failed to execute %s: %w", os.Args[1], err))
+}
+
+// reportChildInitFailure sends err to the tester over the status pipe, and exits.
+func reportChildInitFailure(step string, err error) {
+	encoded, _ := json.Marshal(childInitError{Step: step, Message: err.Error()})
+	os.NewFile(childInitStatusFd, "child-init-status").Write(encoded)
+	os.Exit(childInitFailedExitCode)
+}
@@ -0,0 +1,15 @@
+package executable
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func wrapWithChildInit(cmd *exec.Cmd, config childInitConfig) (*childInitStatus, error) {
+	return nil, errors.New("re-executing the tester binary is not supported on Windows")
+}
+
+func runChildInit(configJSON string) {
+	os.Exit(127)
+}
@@ -0,0 +1,131 @@
+package executable
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// environment holds the changes to be made to the tester's environment before it is passed to the executable.
+type environment struct {
+	shouldClear     bool
+	overrides       map[string]string
+	removedKeys     map[string]bool
+	removedPrefixes []string
+}
+
+func (env *environment) set(key string, value string) {
+	if env.overrides == nil {
+		env.overrides = map[string]string{}
+	}
+
+	env.overrides[key] = value
+	delete(env.removedKeys, key)
+}
+
+func (env *environment) unset(key string) {
+	if env.removedKeys == nil {
+		env.removedKeys = map[string]bool{}
+	}
+
+	env.removedKeys[key] = true
+	delete(env.overrides, key)
+}
+
+func (env *environment) unsetWithPrefix(prefix string) {
+	env.removedPrefixes = append(env.removedPrefixes, prefix)
+
+	for key := range env.overrides {
+		if strings.HasPrefix(key, prefix) {
+			delete(env.overrides, key)
+		}
+	}
+}
+
+func (env *environment) clear() {
+	env.shouldClear = true
+	env.overrides = nil
+	env.removedKeys = nil
+	env.removedPrefixes = nil
+}
+
+func (env environment) clone() environment {
+	cloned := environment{shouldClear: env.shouldClear}
+
+	for key, value := range env.overrides {
+		cloned.set(key, value)
+	}
+
+	for key := range env.removedKeys {
+		cloned.unset(key)
+	}
+
+	cloned.removedPrefixes = append([]string{}, env.removedPrefixes...)
+
+	return cloned
+}
+
+// build returns the environment in the "key=value" format used by exec.Cmd.Env
+func (env environment) build() []string {
+	result := []string{}
+
+	if !env.shouldClear {
+		for _, keyValue := range os.Environ() {
+			key, _, _ := strings.Cut(keyValue, "=")
+
+			if !env.isRemoved(key) {
+				if _, ok := env.overrides[key]; !ok {
+					result = append(result, keyValue)
+				}
+			}
+		}
+	}
+
+	// Sorted so that the environment is the same across runs
+	keys := make([]string, 0, len(env.overrides))
+	for key := range env.overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		result = append(result, key+"="+env.overrides[key])
+	}
+
+	return result
+}
+
+func (env environment) isRemoved(key string) bool {
+	if env.removedKeys[key] {
+		return true
+	}
+
+	for _, prefix := range env.removedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetEnv sets an environment variable for the executable, overriding the value inherited from the tester (if any).
+func (e *Executable) SetEnv(key string, value string) {
+	e.env.set(key, value)
+}
+
+// UnsetEnv removes an environment variable that'd otherwise be inherited from the tester or set using SetEnv.
+func (e *Executable) UnsetEnv(key string) {
+	e.env.unset(key)
+}
+
+// UnsetEnvWithPrefix removes all environment variables that start with prefix. Example: "CODECRAFTERS_"
+func (e *Executable) UnsetEnvWithPrefix(prefix string) {
+	e.env.unsetWithPrefix(prefix)
+}
+
+// ClearEnv stops the executable from inheriting the tester's environment. Only variables set using SetEnv after this
+// call are passed to the executable.
+func (e *Executable) ClearEnv() {
+	e.env.clear()
+}
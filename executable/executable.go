@@ -1,46 +1,117 @@
 package executable
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"io"
 	"os/exec"
 
+	"github.com/creack/pty"
 	"github.com/debanandanayak/tester-utils/linewriter"
 )
 
 // Executable represents a program that can be executed
+//
+// An Executable can be used from multiple goroutines. For example, Kill can be called from a teardown function while
+// another goroutine is blocked in Wait.
 type Executable struct {
 	Path                  string
 	TimeoutInMilliseconds int
 	loggerFunc            func(string)
 
-	ctxWithTimeout context.Context
-	ctxCancelFunc  context.CancelFunc
-
 	// WorkingDir can be set before calling Start or Run to customize the working directory of the executable.
 	WorkingDir string
 
+	// ShouldUsePTY can be set before calling Start or Run to attach the executable to a pseudo-terminal instead of pipes.
+	//
+	// In this mode the program sees a terminal on stdin, stdout & stderr (isatty() returns true). Since a terminal only
+	// has one output stream, stderr is merged into Stdout and Stderr is always empty. The terminal applies its usual
+	// line discipline: input written to StdinPipe is echoed back, "\n" in the output is translated to "\r\n", and
+	// control characters like Ctrl-C (\x03) are turned into signals. Closing StdinPipe sends Ctrl-D (EOF).
+	ShouldUsePTY bool
+
+	// OutputLimitInBytes can be set before calling Start or Run to change how much of stdout & stderr (each) is captured.
+	// Output beyond this is discarded, and reported using StdoutTruncated/StderrTruncated in ExecutableResult. Defaults to 1MB.
+	OutputLimitInBytes int
+
+	// ResourceLimits can be set before calling Start or Run to restrict the resources the executable can use.
+	// MaxCPUTimeInSeconds & MaxOpenFiles are applied before the program is executed, which requires Init to be called at
+	// the start of main().
+	ResourceLimits ResourceLimits
+
+	// ShouldIsolateNetwork can be set before calling Start or Run to run the executable in new (unprivileged) user &
+	// network namespaces, where only the loopback interface is available. This stops programs from reaching external
+	// services, and also means that the tester can't connect to servers the program starts.
+	//
+	// Only supported on Linux, and requires Init to be called at the start of main(). If isolation isn't available (like
+	// when the kernel doesn't allow creating the namespaces), the program is run without it, and a warning is logged &
+	// added to ExecutableResult.Warnings.
+	ShouldIsolateNetwork bool
+
+	// ResourceSamplingInterval can be set before calling Start or Run to record a ResourceSample (open fds, threads,
+	// memory & CPU time) at this interval while the program runs. See ResourceSamples and ExecutableResult.ResourceSamples.
+	ResourceSamplingInterval time.Duration
+
+	// env is applied to the tester's environment before passing it to the executable. Use SetEnv, UnsetEnv & ClearEnv to change it.
+	env environment
+
 	StdinPipe io.WriteCloser
 
-	// These are set & removed together
-	atleastOneReadDone bool
-	cmd                *exec.Cmd
-	stdoutPipe         io.ReadCloser
-	stderrPipe         io.ReadCloser
-	stdoutBytes        []byte
-	stderrBytes        []byte
-	stdoutBuffer       *bytes.Buffer
-	stderrBuffer       *bytes.Buffer
-	stdoutLineWriter   *linewriter.LineWriter
-	stderrLineWriter   *linewriter.LineWriter
-	readDone           chan bool
+	// mutex guards process & StdinPipe, which are set in Start and removed once the process has been waited for. It
+	// also guards the fields used to find leaked processes.
+	mutex   sync.Mutex
+	process *runningProcess
+
+	// These are used to find processes that programs leave behind, see KillLeakedProcesses
+	startedProcesses      []processIdentity
+	knownDescendants      []processIdentity
+	killedLeakedProcesses []LeakedProcess
+}
+
+// runningProcess holds the state of a started program. Fields are set in Start and not changed after that, except the
+// ones that are documented otherwise.
+type runningProcess struct {
+	cmd                  *exec.Cmd
+	startTime            time.Time
+	parentCtx            context.Context // The context passed to StartContext
+	ctxWithTimeout       context.Context // Cancelled with context.DeadlineExceeded as the cause once timeoutTimer fires
+	ctxCancelFunc        context.CancelFunc
+	timeoutTimer         *pausableTimer // Paused while the program is paused, so that it doesn't count towards the timeout
+	stdinPipe            io.WriteCloser
+	stdoutStream         *OutputStream
+	stderrStream         *OutputStream
+	stdoutLineWriter     *linewriter.LineWriter
+	stderrLineWriter     *linewriter.LineWriter
+	outputLines          *outputLines
+	outputTimeline       *outputTimeline
+	readDone             chan bool
+	relayCount           int                   // Number of IO relays that'll send on readDone
+	atleastOneReadDone   atomic.Bool           // Set by the IO relays
+	exited               <-chan bool           // Closed once the program has exited, see watchForExit
+	exitStatus           exitStatus            // Set before exited is closed
+	ptyMaster            *os.File              // Only set if ShouldUsePTY is true
+	ptyCmdWaitDone       chan error            // Only set if ShouldUsePTY is true
+	resourceLimitWatcher *resourceLimitWatcher // Only set if ResourceLimits is not empty
+	resourceSampler      *resourceSampler      // Only set if ResourceSamplingInterval is set
+	stdinScriptDone      chan error            // Only set if the program was started using StartWithStdinScript
+	resourceLimits       ResourceLimits
+	childInitStatus      *childInitStatus // Only set if the tester binary is re-executed to set things up first
+	warnings             []string
+
+	// These are set once, when the process has been waited for. waitDone is closed after that.
+	waitOnce   sync.Once
+	waitDone   chan bool
+	waitResult ExecutableResult
+	waitErr    error
 }
 
 // ExecutableResult holds the result of an executable run
@@ -48,20 +119,65 @@ type ExecutableResult struct {
 	Stdout   []byte
 	Stderr   []byte
 	ExitCode int
+
+	// TerminationSignal is the signal that terminated the program, or 0 if it exited by itself. ExitCode is -1 if this is set.
+	TerminationSignal syscall.Signal
+
+	// TerminationSignalName is the name of TerminationSignal. Example: "SIGSEGV"
+	TerminationSignalName string
+
+	// CoreDumped is true if the program dumped core when it was terminated by a signal.
+	CoreDumped bool
+
+	// TimedOut is true if the program was killed for exceeding Executable.TimeoutInMilliseconds.
+	TimedOut bool
+
+	// StdoutTruncated & StderrTruncated are true if the program's output exceeded Executable.OutputLimitInBytes. In that
+	// case Stdout/Stderr only contain the output up to the limit.
+	StdoutTruncated bool
+	StderrTruncated bool
+
+	// StdoutTotalBytes & StderrTotalBytes are the number of bytes the program wrote, including truncated output.
+	StdoutTotalBytes int64
+	StderrTotalBytes int64
+
+	// ResourceUsage holds the time & resources the program used. Useful for asserting on response times or memory usage.
+	ResourceUsage ResourceUsage
+
+	// ExceededResourceLimit is the limit from Executable.ResourceLimits that the program ran into, if any. Example: "memory"
+	ExceededResourceLimit string
+
+	// ResourceSamples are the samples recorded while the program was running, if Executable.ResourceSamplingInterval was set.
+	ResourceSamples []ResourceSample
+
+	// OutputTimeline holds stdout & stderr as a single list of chunks, in the order they were received. Useful for
+	// checking whether a line on stderr was printed before or after a line on stdout. Truncated output isn't included.
+	OutputTimeline []OutputChunk
+
+	// Warnings are problems that didn't stop the program from running, but might affect the test. Example: network
+	// isolation wasn't available, so the program was run without it.
+	Warnings []string
 }
 
 type loggerWriter struct {
 	loggerFunc func(string)
+
+	// Lines are also recorded, so that tests can wait for specific lines
+	outputLines *outputLines
+	streamName  string
 }
 
-func newLoggerWriter(loggerFunc func(string)) *loggerWriter {
+func newLoggerWriter(loggerFunc func(string), outputLines *outputLines, streamName string) *loggerWriter {
 	return &loggerWriter{
-		loggerFunc: loggerFunc,
+		loggerFunc:  loggerFunc,
+		outputLines: outputLines,
+		streamName:  streamName,
 	}
 }
 
 func (w *loggerWriter) Write(bytes []byte) (n int, err error) {
 	w.loggerFunc(string(bytes[:len(bytes)-1]))
+	w.outputLines.add(w.streamName, strings.TrimSuffix(string(bytes[:len(bytes)-1]), "\r"))
 	return len(bytes), nil
 }
 
@@ -71,10 +187,16 @@ func nullLogger(msg string) {
 
 func (e *Executable) Clone() *Executable {
 	return &Executable{
-		Path:                  e.Path,
-		TimeoutInMilliseconds: e.TimeoutInMilliseconds,
-		loggerFunc:            e.loggerFunc,
-		WorkingDir:            e.WorkingDir,
+		Path:                     e.Path,
+		TimeoutInMilliseconds:    e.TimeoutInMilliseconds,
+		loggerFunc:               e.loggerFunc,
+		WorkingDir:               e.WorkingDir,
+		ShouldUsePTY:             e.ShouldUsePTY,
+		OutputLimitInBytes:       e.OutputLimitInBytes,
+		ResourceLimits:           e.ResourceLimits,
+		ShouldIsolateNetwork:     e.ShouldIsolateNetwork,
+		ResourceSamplingInterval: e.ResourceSamplingInterval,
+		env:                      e.env.clone(),
 	}
 }
 
@@ -88,20 +210,72 @@ func NewVerboseExecutable(path string, loggerFunc func(string)) *Executable {
 	return &Executable{Path: path, TimeoutInMilliseconds: 10 * 1000, loggerFunc: loggerFunc}
 }
 
+// currentProcess returns the running process, or nil if the program isn't running.
+func (e *Executable) currentProcess() *runningProcess {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.process
+}
+
 func (e *Executable) isRunning() bool {
-	return e.cmd != nil
+	return e.currentProcess() != nil
 }
 
 func (e *Executable) HasExited() bool {
-	return e.atleastOneReadDone
+	process := e.currentProcess()
+	if process == nil {
+		return false
+	}
+
+	return process.atleastOneReadDone.Load()
+}
+
+// StdoutStream returns the stdout of the running program, which can be read incrementally while it is running.
+//
+// Returns nil if the program isn't running. Everything the program writes is still included in the result of Wait.
+func (e *Executable) StdoutStream() *OutputStream {
+	process := e.currentProcess()
+	if process == nil {
+		return nil
+	}
+
+	return process.stdoutStream
+}
+
+// StderrStream returns the stderr of the running program, which can be read incrementally while it is running.
+//
+// Returns nil if the program isn't running. In PTY mode stderr is merged into stdout, so this stream is always empty.
+func (e *Executable) StderrStream() *OutputStream {
+	process := e.currentProcess()
+	if process == nil {
+		return nil
+	}
+
+	return process.stderrStream
 }
 
 // Start starts the specified command but does not wait for it to complete.
 func (e *Executable) Start(args ...string) error {
+	return e.StartContext(context.Background(), args...)
+}
+
+// StartContext is like Start, but the program is killed if ctx is cancelled before it exits.
+func (e *Executable) StartContext(ctx context.Context, args ...string) error {
+	_, err := e.start(ctx, nil, args)
+	return err
+}
+
+// start starts the program, and runs stdinScript in the background if it isn't nil. The returned process can be used
+// without holding the mutex, unlike e.process & e.StdinPipe which are reset once the program has been waited for.
+func (e *Executable) start(ctx context.Context, stdinScript *StdinScript, args []string) (*runningProcess, error) {
 	var err error
 
-	if e.isRunning() {
-		return errors.New("process already in progress")
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.process != nil {
+		return nil, errors.New("process already in progress")
 	}
 
 	var absolutePath, resolvedPath string
@@ -114,134 +288,482 @@ func (e *Executable) Start(args ...string) error {
 	} else {
 		absolutePath, err = filepath.Abs(e.Path)
 		if err != nil {
-			return fmt.Errorf("%s not found", filepath.Base(e.Path))
+			return nil, fmt.Errorf("%s not found", filepath.Base(e.Path))
 		}
 	}
 	fileInfo, err := os.Stat(absolutePath)
 	if err != nil {
-		return fmt.Errorf("%s not found", filepath.Base(e.Path))
+		return nil, fmt.Errorf("%s not found", filepath.Base(e.Path))
 	}
 
 	// Check executable permission
 	if fileInfo.Mode().Perm()&0111 == 0 || fileInfo.IsDir() {
-		return fmt.Errorf("%s is not an executable file", e.Path)
+		return nil, fmt.Errorf("%s is not an executable file", e.Path)
+	}
+
+	process := &runningProcess{
+		parentCtx:      ctx,
+		readDone:       make(chan bool),
+		waitDone:       make(chan bool),
+		resourceLimits: e.ResourceLimits,
+	}
+
+	// context.WithTimeout can't be paused, so we cancel the context ourselves when the timeout elapses
+	ctxWithTimeout, cancelCauseFunc := context.WithCancelCause(ctx)
+	process.ctxWithTimeout = ctxWithTimeout
+	process.ctxCancelFunc = func() { cancelCauseFunc(context.Canceled) }
+	process.timeoutTimer = newPausableTimer(time.Duration(e.TimeoutInMilliseconds)*time.Millisecond, func() {
+		cancelCauseFunc(context.DeadlineExceeded)
+	})
+
+	shouldIsolateNetwork := e.ShouldIsolateNetwork
+
+	err = e.buildAndStartCmd(process, args, shouldIsolateNetwork)
+
+	if err != nil && shouldIsolateNetwork && isNetworkIsolationUnavailableError(err) {
+		// Recorded on the result too, since loggerFunc doesn't log anything for executables created using NewExecutable
+		warning := fmt.Sprintf("Network isolation isn't available (%s), the program was run without it.", err)
+		process.warnings = append(process.warnings, warning)
+		e.loggerFunc("Warning: " + warning)
+
+		err = e.buildAndStartCmd(process, args, false)
+	}
+
+	if err != nil {
+		process.ctxCancelFunc()
+		return nil, err
+	}
+
+	if e.ResourceSamplingInterval > 0 {
+		process.resourceSampler = startResourceSampler(process.cmd.Process.Pid, process.startTime, e.ResourceSamplingInterval)
+	}
+
+	if stdinScript != nil {
+		process.stdinScriptDone = make(chan error, 1)
+
+		go func() {
+			err := process.runStdinScript(stdinScript)
+			if err != nil {
+				process.ctxCancelFunc() // Kills the program, the script can't continue
+			}
+
+			process.stdinScriptDone <- err
+		}()
+	}
+
+	// At this point, it is safe to set e.process, if any of the above steps fail, we don't want to leave it in an inconsistent state
+	e.process = process
+	e.StdinPipe = process.stdinPipe
+	e.startedProcesses = append(e.startedProcesses, readProcessIdentity(process.cmd.Process.Pid))
+
+	return process, nil
+}
+
+func (e *Executable) buildAndStartCmd(process *runningProcess, args []string, shouldIsolateNetwork bool) error {
+	cmd, err := e.buildCmd(process, args, shouldIsolateNetwork)
+	if err != nil {
+		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.TimeoutInMilliseconds)*time.Millisecond)
-	e.ctxWithTimeout = ctx
-	e.ctxCancelFunc = cancel
+	return e.startCmd(cmd, process)
+}
 
-	cmd := exec.CommandContext(ctx, e.Path, args...)
+// buildCmd returns the command to run the program with, ready to be started using startCmd.
+func (e *Executable) buildCmd(process *runningProcess, args []string, shouldIsolateNetwork bool) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(process.ctxWithTimeout, e.Path, args...)
 	cmd.Dir = e.WorkingDir
-	cmd.SysProcAttr = createProcAttribute()
-	e.readDone = make(chan bool)
-	e.atleastOneReadDone = false
 
+	// exec resolves relative paths from cmd.Dir, but Start validates e.Path from the tester's working directory
+	if cmd.Dir != "" && !filepath.IsAbs(cmd.Path) {
+		if absolutePath, err := filepath.Abs(cmd.Path); err == nil {
+			cmd.Path = absolutePath
+		}
+	}
+	cmd.Env = e.env.build()
+
+	if e.ShouldUsePTY {
+		cmd.SysProcAttr = createPTYProcAttribute()
+	} else {
+		cmd.SysProcAttr = createProcAttribute()
+	}
+
+	// By default only the process itself is killed when the context is done, we want to kill its children too
+	cmd.Cancel = func() error {
+		signalProcessGroup(cmd.Process.Pid, syscall.SIGKILL)
+		return cmd.Process.Kill()
+	}
+
+	process.childInitStatus = nil
+
+	childInitConfig := childInitConfig{ResourceLimits: e.ResourceLimits}
+
+	if shouldIsolateNetwork {
+		if err := isolateNetwork(cmd); err != nil {
+			return nil, &childInitError{Step: childInitStepNetwork, Message: err.Error()}
+		}
+
+		childInitConfig.ShouldBringUpLoopback = true
+	}
+
+	// Resource limits are applied by the re-executed tester binary right before executing the program, so that the
+	// program never runs without them
+	if childInitConfig.ShouldBringUpLoopback || e.ResourceLimits.hasKernelEnforcedLimits() {
+		childInitStatus, err := wrapWithChildInit(cmd, childInitConfig)
+		if err != nil {
+			if shouldIsolateNetwork {
+				return nil, &childInitError{Step: childInitStepNetwork, Message: err.Error()}
+			}
+
+			return nil, fmt.Errorf("failed to apply resource limits: %w", err)
+		}
+
+		process.childInitStatus = childInitStatus
+	}
+
+	return cmd, nil
+}
+
+// isNetworkIsolationUnavailableError returns true if err means that the program can't be run with network isolation,
+// but can be run without it.
+func isNetworkIsolationUnavailableError(err error) bool {
+	var childInitErr *childInitError
+	if errors.As(err, &childInitErr) {
+		return childInitErr.Step == childInitStepNetwork
+	}
+
+	return isNetworkNamespaceUnsupportedError(err)
+}
+
+func (e *Executable) startCmd(cmd *exec.Cmd, process *runningProcess) error {
+	becomeChildSubreaper()
+
+	startingProcessesMutex.RLock()
+	defer startingProcessesMutex.RUnlock()
+
+	var err error
+	if e.ShouldUsePTY {
+		err = e.startWithPTY(cmd, process)
+	} else {
+		err = e.startWithPipes(cmd, process)
+	}
+
+	if err != nil && process.childInitStatus != nil {
+		process.childInitStatus.close()
+	}
+
+	if cmd.Process != nil {
+		addProcessStartedByExecutable(readProcessIdentity(cmd.Process.Pid))
+	}
+
+	return err
+}
+
+// waitForChildInit waits until the program has been executed, if cmd runs the tester binary to set things up first
+// (see wrapWithChildInit). If that fails, the process is waited for and an error is returned.
+func (e *Executable) waitForChildInit(cmd *exec.Cmd, process *runningProcess) error {
+	if process.childInitStatus == nil {
+		return nil
+	}
+
+	if err := process.childInitStatus.wait(); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	return nil
+}
+
+// startWithPipes starts cmd with stdin, stdout & stderr attached to pipes.
+func (e *Executable) startWithPipes(cmd *exec.Cmd, process *runningProcess) error {
 	// Setup stdout capture
-	e.stdoutPipe, err = cmd.StdoutPipe()
+	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
 		return err
 	}
-	e.stdoutBytes = []byte{}
-	e.stdoutBuffer = bytes.NewBuffer(e.stdoutBytes)
-	e.stdoutLineWriter = linewriter.New(newLoggerWriter(e.loggerFunc), 500*time.Millisecond)
+	process.outputLines = newOutputLines()
+	process.stdoutStream = newOutputStream("stdout")
+	process.stdoutLineWriter = linewriter.New(newLoggerWriter(e.loggerFunc, process.outputLines, "stdout"), 500*time.Millisecond)
 
 	// Setup stderr relay
-	e.stderrPipe, err = cmd.StderrPipe()
+	stderrPipe, err := cmd.StderrPipe()
 	if err != nil {
 		return err
 	}
-	e.stderrBytes = []byte{}
-	e.stderrBuffer = bytes.NewBuffer(e.stderrBytes)
-	e.stderrLineWriter = linewriter.New(newLoggerWriter(e.loggerFunc), 500*time.Millisecond)
+	process.stderrStream = newOutputStream("stderr")
+	process.stderrLineWriter = linewriter.New(newLoggerWriter(e.loggerFunc, process.outputLines, "stderr"), 500*time.Millisecond)
 
-	e.StdinPipe, err = cmd.StdinPipe()
+	process.stdinPipe, err = cmd.StdinPipe()
 	if err != nil {
 		return err
 	}
+	process.startTime = time.Now()
+	process.outputTimeline = newOutputTimeline(process.startTime)
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	if err = e.waitForChildInit(cmd, process); err != nil {
+		return err
+	}
+
+	process.exited = watchForExit(cmd.Process.Pid, &process.exitStatus)
+	e.setupResourceLimits(cmd, process)
+
+	process.cmd = cmd
+	e.setupIORelay(process, stdoutPipe, process.stdoutStream, process.stdoutLineWriter)
+	e.setupIORelay(process, stderrPipe, process.stderrStream, process.stderrLineWriter)
+
+	return nil
+}
+
+// startWithPTY starts cmd with stdin, stdout & stderr attached to a new pseudo-terminal.
+func (e *Executable) startWithPTY(cmd *exec.Cmd, process *runningProcess) error {
+	ptyMaster, ptySlave, err := pty.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+
+	// Some programs query the terminal size, a zero-sized terminal can confuse them.
+	if err := pty.Setsize(ptyMaster, &pty.Winsize{Rows: 24, Cols: 80}); err != nil {
+		ptyMaster.Close()
+		ptySlave.Close()
+		return fmt.Errorf("failed to set pseudo-terminal size: %w", err)
+	}
+
+	cmd.Stdin = ptySlave
+	cmd.Stdout = ptySlave
+	cmd.Stderr = ptySlave
+
+	process.startTime = time.Now()
+	process.outputTimeline = newOutputTimeline(process.startTime)
 	err = cmd.Start()
 	if err != nil {
+		ptyMaster.Close()
+		ptySlave.Close()
+		return err
+	}
+
+	if err = e.waitForChildInit(cmd, process); err != nil {
+		ptyMaster.Close()
+		ptySlave.Close()
 		return err
 	}
 
-	// At this point, it is safe to set e.cmd as cmd, if any of the above steps fail, we don't want to leave e.cmd in an inconsistent state
-	e.cmd = cmd
-	e.setupIORelay(e.stdoutPipe, e.stdoutBuffer, e.stdoutLineWriter)
-	e.setupIORelay(e.stderrPipe, e.stderrBuffer, e.stderrLineWriter)
+	process.exited = watchForExit(cmd.Process.Pid, &process.exitStatus)
+	e.setupResourceLimits(cmd, process)
+
+	// Reads from the master only fail once every copy of the slave is closed, including ours. Closing ours right away
+	// can lose output written right before the program exits, so we hold on to it until the program has exited.
+	cmdWaitDone := make(chan error, 1)
+	exited := process.exited
+	go func() {
+		<-exited // Reaping the process first would hide how it exited from watchForExit
+		err := cmd.Wait()
+		ptySlave.Close()
+		cmdWaitDone <- err
+	}()
+	process.ptyCmdWaitDone = cmdWaitDone
+
+	process.ptyMaster = ptyMaster
+	process.outputLines = newOutputLines()
+	process.stdoutStream = newOutputStream("stdout")
+	process.stdoutLineWriter = linewriter.New(newLoggerWriter(e.loggerFunc, process.outputLines, "stdout"), 500*time.Millisecond)
+	process.stderrStream = newOutputStream("stderr")
+	process.stderrStream.close() // stderr is merged into stdout
+	process.stderrLineWriter = linewriter.New(newLoggerWriter(e.loggerFunc, process.outputLines, "stderr"), 500*time.Millisecond)
+	process.stdinPipe = &ptyStdin{ptyMaster: ptyMaster}
+
+	process.cmd = cmd
+	e.setupIORelay(process, ptyMaster, process.stdoutStream, process.stdoutLineWriter)
 
 	return nil
 }
 
-func (e *Executable) setupIORelay(source io.Reader, destination1 io.Writer, destination2 io.Writer) {
+// setupResourceLimits starts watching a process that was just started for exceeding ResourceLimits. The limits that the
+// kernel enforces were already applied before the program was executed, see buildCmd.
+func (e *Executable) setupResourceLimits(cmd *exec.Cmd, process *runningProcess) {
+	if e.ResourceLimits.isEmpty() {
+		return
+	}
+
+	// The process is always started in a new process group, so pid == pgid
+	process.resourceLimitWatcher = startResourceLimitWatcher(cmd.Process.Pid, e.ResourceLimits)
+}
+
+// ptyStdin writes to a pseudo-terminal. Closing it sends EOF (Ctrl-D) instead of closing the terminal, since the
+// terminal is also used to read the program's output.
+type ptyStdin struct {
+	ptyMaster *os.File
+}
+
+func (s *ptyStdin) Write(bytes []byte) (n int, err error) {
+	return s.ptyMaster.Write(bytes)
+}
+
+func (s *ptyStdin) Close() error {
+	_, err := s.ptyMaster.Write([]byte{0x04})
+	return err
+}
+
+func (e *Executable) setupIORelay(process *runningProcess, source io.Reader, destination1 *OutputStream, destination2 io.Writer) {
+	outputLimit := int64(e.outputLimitOrDefault())
+	loggerFunc := e.loggerFunc
+	process.relayCount++
+
 	go func() {
-		combinedDestination := io.MultiWriter(destination1, destination2)
-		bytesWritten, err := io.Copy(combinedDestination, io.LimitReader(source, 1024*1024)) // 1MB
-		if err != nil {
+		combinedDestination := io.MultiWriter(destination1, destination2, process.outputTimeline.writer(destination1.name))
+		bytesWritten, err := io.Copy(combinedDestination, io.LimitReader(source, outputLimit))
+		if err != nil && !isPTYClosedError(err) {
 			panic(err)
 		}
 
-		if bytesWritten == 1024*1024 {
-			e.loggerFunc("Warning: Logs exceeded allowed limit, output might be truncated.\n")
+		// Let's drain the pipe in case any content is leftover, so that the program doesn't block on a full pipe
+		bytesDiscarded, _ := io.Copy(io.Discard, source)
+		if bytesDiscarded > 0 {
+			loggerFunc("Warning: Logs exceeded allowed limit, output might be truncated.\n")
+			destination1.markTruncated(bytesWritten + bytesDiscarded)
 		}
 
-		e.atleastOneReadDone = true
-		e.readDone <- true
-		io.Copy(io.Discard, source) // Let's drain the pipe in case any content is leftover
+		destination1.close()
+		process.atleastOneReadDone.Store(true)
+		process.readDone <- true
 	}()
 }
 
+func (e *Executable) outputLimitOrDefault() int {
+	if e.OutputLimitInBytes == 0 {
+		return 1024 * 1024 // 1MB
+	}
+
+	return e.OutputLimitInBytes
+}
+
 // Run starts the specified command, waits for it to complete and returns the
 // result.
 func (e *Executable) Run(args ...string) (ExecutableResult, error) {
+	return e.RunContext(context.Background(), args...)
+}
+
+// RunContext is like Run, but the program is killed if ctx is cancelled before it exits.
+func (e *Executable) RunContext(ctx context.Context, args ...string) (ExecutableResult, error) {
 	var err error
 
-	if err = e.Start(args...); err != nil {
+	if err = e.StartContext(ctx, args...); err != nil {
 		return ExecutableResult{}, err
 	}
 
-	return e.Wait()
+	return e.WaitContext(ctx)
 }
 
 // RunWithStdin starts the specified command, sends input, waits for it to complete and returns the
 // result.
 func (e *Executable) RunWithStdin(stdin []byte, args ...string) (ExecutableResult, error) {
-	var err error
-
-	if err = e.Start(args...); err != nil {
+	process, err := e.start(context.Background(), nil, args)
+	if err != nil {
 		return ExecutableResult{}, err
 	}
 
-	e.StdinPipe.Write(stdin)
+	if _, err = process.stdinPipe.Write(stdin); err != nil && !isStdinClosedError(err) {
+		e.Kill()
+		return ExecutableResult{}, fmt.Errorf("failed to write to stdin: %w", err)
+	}
 
-	return e.Wait()
+	return e.waitForProcess(context.Background(), process)
 }
 
 // Wait waits for the program to finish and results the result
 func (e *Executable) Wait() (ExecutableResult, error) {
+	return e.WaitContext(context.Background())
+}
+
+// WaitContext is like Wait, but if ctx is done before the program exits, the program is killed and ctx's error is
+// returned.
+//
+// It is safe to call this from multiple goroutines, all of them receive the same result.
+func (e *Executable) WaitContext(ctx context.Context) (ExecutableResult, error) {
+	process := e.currentProcess()
+	if process == nil {
+		return ExecutableResult{}, errors.New("program is not running")
+	}
+
+	return e.waitForProcess(ctx, process)
+}
+
+func (e *Executable) waitForProcess(ctx context.Context, process *runningProcess) (ExecutableResult, error) {
+	e.startWaitingForProcess(process)
+
+	select {
+	case <-process.waitDone:
+		return process.waitResult, process.waitErr
+	case <-ctx.Done():
+		// Killing the process group makes the program exit (and its output pipes close), so this doesn't block for long
+		process.ctxCancelFunc()
+		<-process.waitDone
+
+		return process.waitResult, fmt.Errorf("execution cancelled: %w", ctx.Err())
+	}
+}
+
+// startWaitingForProcess starts waiting for the process in the background, if that hasn't been started already.
+// process.waitDone is closed once that's done.
+func (e *Executable) startWaitingForProcess(process *runningProcess) {
+	process.waitOnce.Do(func() {
+		go func() {
+			process.waitResult, process.waitErr = process.wait()
+
+			e.mutex.Lock()
+			if e.process == process {
+				e.process = nil
+				e.StdinPipe = nil
+			}
+			e.mutex.Unlock()
+
+			close(process.waitDone)
+		}()
+	})
+}
+
+// wait waits for the program to finish and returns the result. Must only be called once.
+func (process *runningProcess) wait() (ExecutableResult, error) {
 	defer func() {
-		e.ctxCancelFunc()
-		e.atleastOneReadDone = false
-		e.cmd = nil
-		e.ctxCancelFunc = nil
-		e.ctxWithTimeout = nil
-		e.stdoutPipe = nil
-		e.stderrPipe = nil
-		e.stdoutBuffer = nil
-		e.stderrBuffer = nil
-		e.stdoutBytes = nil
-		e.stderrBytes = nil
-		e.stdoutLineWriter = nil
-		e.stderrLineWriter = nil
-		e.readDone = nil
-		e.StdinPipe = nil
+		process.timeoutTimer.stop()
+		process.ctxCancelFunc()
+		if process.ptyMaster != nil {
+			process.ptyMaster.Close()
+		}
 	}()
 
-	e.StdinPipe.Close()
+	var stdinScriptErr error
+	if process.stdinScriptDone != nil {
+		stdinScriptErr = <-process.stdinScriptDone // The script closes stdin itself, unless it was asked to keep it open
+	} else {
+		process.stdinPipe.Close()
+	}
+
+	for i := 0; i < process.relayCount; i++ {
+		<-process.readDone
+	}
+
+	var err error
+	if process.ptyMaster != nil {
+		err = <-process.ptyCmdWaitDone // cmd.Wait() was already called when starting
+	} else {
+		<-process.exited // Reaping the process first would hide how it exited from watchForExit
+		err = process.cmd.Wait()
+	}
+	wallTime := time.Since(process.startTime)
 
-	<-e.readDone
-	<-e.readDone
+	var resourceSamples []ResourceSample
+	if process.resourceSampler != nil {
+		resourceSamples = process.resourceSampler.stop()
+	}
 
-	err := e.cmd.Wait()
+	exceededResourceLimit := ""
+	if process.resourceLimitWatcher != nil {
+		exceededResourceLimit = process.resourceLimitWatcher.stop(process.cmd.ProcessState)
+	}
 
 	if err != nil {
 		// Ignore exit errors, we'd rather send the exit code back
@@ -250,36 +772,81 @@ func (e *Executable) Wait() (ExecutableResult, error) {
 		}
 	}
 
-	e.stdoutLineWriter.Flush()
-	e.stderrLineWriter.Flush()
+	process.stdoutLineWriter.Flush()
+	process.stderrLineWriter.Flush()
 
-	stdout := e.stdoutBuffer.Bytes()
-	stderr := e.stderrBuffer.Bytes()
+	stdout := process.stdoutStream.Bytes()
+	stderr := process.stderrStream.Bytes()
+	stdoutTruncated, stdoutTotalBytes := process.stdoutStream.truncationInfo()
+	stderrTruncated, stderrTotalBytes := process.stderrStream.truncationInfo()
 
 	result := ExecutableResult{
 		Stdout:   stdout,
 		Stderr:   stderr,
-		ExitCode: e.cmd.ProcessState.ExitCode(),
+		ExitCode: process.cmd.ProcessState.ExitCode(),
+
+		StdoutTruncated:  stdoutTruncated,
+		StderrTruncated:  stderrTruncated,
+		StdoutTotalBytes: stdoutTotalBytes,
+		StderrTotalBytes: stderrTotalBytes,
+
+		ResourceUsage: ResourceUsage{WallTime: wallTime},
+
+		ExceededResourceLimit: exceededResourceLimit,
+
+		ResourceSamples: resourceSamples,
+		OutputTimeline:  process.outputTimeline.snapshot(),
+
+		Warnings: process.warnings,
+	}
+
+	readResourceUsage(process.cmd.ProcessState, &result.ResourceUsage)
+	readTerminationStatus(process.cmd.ProcessState, &result)
+
+	if process.parentCtx.Err() != nil {
+		return result, fmt.Errorf("execution cancelled: %w", process.parentCtx.Err())
+	}
+
+	if context.Cause(process.ctxWithTimeout) == context.DeadlineExceeded {
+		result.TimedOut = true
+		return result, fmt.Errorf("execution timed out")
+	}
+
+	if err := process.resourceLimits.exceededLimitError(exceededResourceLimit); err != nil {
+		return result, err
 	}
 
-	if e.ctxWithTimeout.Err() == context.DeadlineExceeded {
-		return ExecutableResult{}, fmt.Errorf("execution timed out")
+	if stdinScriptErr != nil {
+		return result, fmt.Errorf("stdin script failed: %w", stdinScriptErr)
 	}
+
 	return result, nil
 }
 
-// Kill terminates the program
+// Kill terminates the program. It is sent SIGTERM first, and SIGKILL if it doesn't exit within 2 seconds.
 func (e *Executable) Kill() error {
-	if !e.isRunning() {
+	return e.Stop(2 * time.Second)
+}
+
+// Stop terminates the program gracefully. SIGTERM is sent to the program's process group, and if the program doesn't
+// exit within gracePeriod SIGKILL is sent. An error is returned if SIGKILL had to be sent.
+func (e *Executable) Stop(gracePeriod time.Duration) error {
+	process := e.currentProcess()
+	if process == nil {
 		return nil
 	}
 
+	pid := process.cmd.Process.Pid
 	doneChannel := make(chan error, 1)
 
+	// Descendants that left the process group won't be found once they're orphaned, so we look for them before killing
+	e.recordDescendants(pid)
+
 	go func() {
-		killProcess(e.cmd.Process.Pid)
-		killProcess(-e.cmd.Process.Pid)
-		_, err := e.Wait()
+		killProcess(pid)
+		killProcess(-pid)
+		resumeProcessGroup(pid) // A paused program can't handle SIGTERM until it is resumed
+		_, err := e.waitForProcess(context.Background(), process)
 		doneChannel <- err
 	}()
 
@@ -287,16 +854,87 @@ func (e *Executable) Kill() error {
 	select {
 	case doneError := <-doneChannel:
 		err = doneError
-	case <-time.After(2 * time.Second):
-		cmd := e.cmd
-		if cmd != nil {
-			err = fmt.Errorf("program failed to exit in 2 seconds after receiving sigterm")
-			killProcess(cmd.Process.Pid)
-			killProcess(-cmd.Process.Pid)
-
-			<-doneChannel // Wait for Wait() to return
-		}
+	case <-time.After(gracePeriod):
+		err = fmt.Errorf("program failed to exit in %s after receiving sigterm", formatDuration(gracePeriod))
+		signalProcess(pid, syscall.SIGKILL)
+		signalProcessGroup(pid, syscall.SIGKILL)
+		e.killKnownDescendants() // These might be holding on to the program's stdout/stderr, which Wait() blocks on
+
+		<-doneChannel // Wait for Wait() to return
 	}
 
+	e.killLeakedProcessesAfterStop()
+
 	return err
 }
+
+// Pause freezes the program and any processes it started (using SIGSTOP on its process group). Time spent paused
+// doesn't count towards TimeoutInMilliseconds. Useful for simulating a node that stops responding, like a replica that
+// misses heartbeats.
+func (e *Executable) Pause() error {
+	process := e.currentProcess()
+	if process == nil {
+		return errors.New("program is not running")
+	}
+
+	// The clock is paused first, so that it doesn't run out while the program is frozen
+	process.timeoutTimer.pause()
+
+	if err := pauseProcessGroup(process.cmd.Process.Pid); err != nil {
+		process.timeoutTimer.resume()
+		return err
+	}
+
+	return nil
+}
+
+// Resume unfreezes a program that was frozen using Pause (using SIGCONT on its process group).
+func (e *Executable) Resume() error {
+	process := e.currentProcess()
+	if process == nil {
+		return errors.New("program is not running")
+	}
+
+	if err := resumeProcessGroup(process.cmd.Process.Pid); err != nil {
+		return err
+	}
+
+	process.timeoutTimer.resume()
+
+	return nil
+}
+
+// SendSignal sends signal to the program. Example: syscall.SIGHUP to test reloading configuration.
+func (e *Executable) SendSignal(signal syscall.Signal) error {
+	process := e.currentProcess()
+	if process == nil {
+		return errors.New("program is not running")
+	}
+
+	return signalProcess(process.cmd.Process.Pid, signal)
+}
+
+// SendSignalToProcessGroup sends signal to the program and any processes it started (unless they moved to a different
+// process group). Example: syscall.SIGSTOP & syscall.SIGCONT to pause & resume the program.
+func (e *Executable) SendSignalToProcessGroup(signal syscall.Signal) error {
+	process := e.currentProcess()
+	if process == nil {
+		return errors.New("program is not running")
+	}
+
+	// The process is always started in a new process group, so pid == pgid
+	return signalProcessGroup(process.cmd.Process.Pid, signal)
+}
+
+// formatDuration formats durations for error messages. Example: "2 seconds", "500 milliseconds"
+func formatDuration(duration time.Duration) string {
+	if duration%time.Second == 0 {
+		if duration == time.Second {
+			return "1 second"
+		}
+
+		return fmt.Sprintf("%d seconds", duration/time.Second)
+	}
+
+	return fmt.Sprintf("%d milliseconds", duration/time.Millisecond)
+}
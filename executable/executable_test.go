@@ -1,12 +1,29 @@
 package executable
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+func TestMain(m *testing.M) {
+	// Network isolation re-executes the test binary
+	Init()
+
+	os.Exit(m.Run())
+}
+
 func TestStart(t *testing.T) {
 	err := NewExecutable("/blah").Start()
 	assertErrorContains(t, err, "not found")
@@ -89,6 +106,27 @@ func TestLargeOutputCapture(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 1024*1024, len(result.Stdout))
 	assert.Equal(t, "blah\n", string(result.Stderr))
+	assert.True(t, result.StdoutTruncated)
+	assert.Equal(t, int64(50000*63), result.StdoutTotalBytes)
+	assert.False(t, result.StderrTruncated)
+	assert.Equal(t, int64(5), result.StderrTotalBytes)
+
+	e.OutputLimitInBytes = 4 * 1024 * 1024
+	e.TimeoutInMilliseconds = 60 * 1000 // Capturing all 50,000 lines is slow with -race
+	result, err = e.Run("hey")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 50000*63, len(result.Stdout))
+	assert.False(t, result.StdoutTruncated)
+	assert.Equal(t, int64(50000*63), result.StdoutTotalBytes)
+
+	e.OutputLimitInBytes = 10
+	result, err = e.Run("hey")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Welcome - ", string(result.Stdout))
+	assert.True(t, result.StdoutTruncated)
+	assert.Equal(t, "blah\n", string(result.Stderr))
 }
 
 func TestExitCode(t *testing.T) {
@@ -174,6 +212,61 @@ func TestRunWithStdin(t *testing.T) {
 	assert.Equal(t, result.ExitCode, 1)
 }
 
+func TestRunWithStdinScript(t *testing.T) {
+	e := NewExecutable("bash")
+
+	script := NewStdinScript().
+		WriteString("first\n").
+		WaitForStdout(regexp.MustCompile(`got: first\n`), time.Second).
+		WriteString("par").
+		Sleep(100 * time.Millisecond).
+		WriteString("tial\n")
+
+	// The second read times out after receiving part of the line
+	result, err := e.RunWithStdinScript(script, "-c", `read -r line; echo "got: $line"; IFS= read -r -t 0.05 line; echo "partial: $line"; cat; echo eof`)
+	assert.NoError(t, err)
+	assert.Equal(t, "got: first\npartial: par\ntial\neof\n", string(result.Stdout))
+
+	// Stdin is left open, so the program must act before EOF
+	e.TimeoutInMilliseconds = 500
+	result, err = e.RunWithStdinScript(NewStdinScript().WriteString("hey\n").KeepStdinOpen(), "-c", "read line; echo \"got $line\"")
+	assert.NoError(t, err)
+	assert.Equal(t, "got hey\n", string(result.Stdout))
+
+	result, err = e.RunWithStdinScript(NewStdinScript().WriteString("hey\n").KeepStdinOpen(), "-c", "cat")
+	assert.EqualError(t, err, "execution timed out")
+	assert.Equal(t, "hey\n", string(result.Stdout))
+
+	// The program is killed if the script fails
+	e.TimeoutInMilliseconds = 10 * 1000
+	startTime := time.Now()
+	_, err = e.RunWithStdinScript(NewStdinScript().WriteString("hey\n").WaitForStderr(regexp.MustCompile("error"), 100*time.Millisecond), "-c", "cat")
+	assert.EqualError(t, err, `stdin script failed: timed out after 100ms waiting for output matching "error" on stderr, received: ""`)
+	assert.Less(t, time.Since(startTime), time.Second)
+
+	// The program exits without reading stdin
+	result, err = e.RunWithStdinScript(NewStdinScript().Sleep(100*time.Millisecond).Write(make([]byte, 1024*1024)), "-c", "exit 0")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+
+	result, err = e.RunWithStdin(make([]byte, 1024*1024), "-c", "exit 0")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+
+	// Sleeping doesn't hold up the result once the program is done
+	startTime = time.Now()
+	result, err = e.RunWithStdinScript(NewStdinScript().Sleep(10*time.Second).WriteString("hey\n"), "-c", "exit 3")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.ExitCode)
+	assert.Less(t, time.Since(startTime), time.Second)
+
+	e.TimeoutInMilliseconds = 200
+	startTime = time.Now()
+	_, err = e.RunWithStdinScript(NewStdinScript().Sleep(10*time.Second).WriteString("hey\n"), "-c", "cat")
+	assert.EqualError(t, err, "execution timed out")
+	assert.Less(t, time.Since(startTime), time.Second)
+}
+
 func TestRunWithStdinTimeout(t *testing.T) {
 	e := NewExecutable("sleep")
 	e.TimeoutInMilliseconds = 50
@@ -208,3 +301,766 @@ func TestTerminatesRoguePrograms(t *testing.T) {
 	err = e.Kill()
 	assert.EqualError(t, err, "program failed to exit in 2 seconds after receiving sigterm")
 }
+
+func TestPTY(t *testing.T) {
+	e := NewExecutable("bash")
+	e.ShouldUsePTY = true
+
+	result, err := e.Run("-c", "[ -t 0 ] && [ -t 1 ] && [ -t 2 ] && echo tty && echo err 1>&2")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Equal(t, "tty\r\nerr\r\n", string(result.Stdout))
+	assert.Equal(t, "", string(result.Stderr))
+
+	// Pipes are used by default
+	e = NewExecutable("bash")
+
+	result, err = e.Run("-c", "[ -t 1 ] || echo not a tty")
+	assert.NoError(t, err)
+	assert.Equal(t, "not a tty\n", string(result.Stdout))
+}
+
+func TestPTYPromptAndEOF(t *testing.T) {
+	e := NewExecutable("bash")
+	e.ShouldUsePTY = true
+
+	err := e.Start("-c", "printf '$ '; read line; echo \"got $line\"; cat; echo done")
+	assert.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond) // Let the prompt be printed before the input is echoed
+	e.StdinPipe.Write([]byte("hello\n"))
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, e.HasExited(), "Expected to not have exited")
+
+	// Wait closes stdin, which sends Ctrl-D to cat
+	result, err := e.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Equal(t, "$ hello\r\ngot hello\r\ndone\r\n", string(result.Stdout))
+}
+
+func TestPTYInterrupt(t *testing.T) {
+	e := NewExecutable("bash")
+	e.ShouldUsePTY = true
+
+	err := e.Start("-c", "trap 'echo interrupted; exit 3' INT; sleep 10 & wait")
+	assert.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+	e.StdinPipe.Write([]byte{0x03}) // Ctrl-C
+
+	result, err := e.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.ExitCode)
+	assert.Contains(t, string(result.Stdout), "interrupted")
+}
+
+func TestResourceLimits(t *testing.T) {
+	e := NewExecutable("bash")
+	e.ResourceLimits = ResourceLimits{MaxMemoryInBytes: 50 * 1024 * 1024}
+
+	// Reserving address space (like the Go runtime & the JVM do on startup) doesn't count, only memory that is used
+	result, err := e.Run("-c", "python3 -c 'import mmap; mmap.mmap(-1, 400 * 1024 * 1024); print(\"reserved\")'")
+	assert.NoError(t, err)
+	assert.Equal(t, "reserved\n", string(result.Stdout))
+
+	goProgram := NewExecutable(os.Args[0]) // This test binary
+	goProgram.ResourceLimits = ResourceLimits{MaxMemoryInBytes: 50 * 1024 * 1024}
+
+	result, err = goProgram.Run("-test.run=^$")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+
+	// Processes that are within the limit on their own are killed once they exceed it together
+	result, err = e.Run("-c", "for i in 1 2 3 4; do (head -c 20000000 /dev/zero; sleep 5) | tail & done; wait")
+	assert.EqualError(t, err, "program exceeded memory limit of 50 MB")
+	assert.Equal(t, ResourceLimitMemory, result.ExceededResourceLimit)
+
+	e.ResourceLimits = ResourceLimits{MaxProcesses: 5}
+
+	result, err = e.Run("-c", "for i in $(seq 1 10); do sleep 5 & done; wait")
+	assert.EqualError(t, err, "program exceeded limit of 5 processes")
+	assert.Equal(t, ResourceLimitProcesses, result.ExceededResourceLimit)
+
+	e.ResourceLimits = ResourceLimits{MaxCPUTimeInSeconds: 1}
+
+	result, err = e.Run("-c", "while :; do :; done")
+	assert.EqualError(t, err, "program exceeded CPU time limit of 1 seconds")
+	assert.Equal(t, ResourceLimitCPUTime, result.ExceededResourceLimit)
+
+	// Hitting the open files limit doesn't terminate the program
+	e.ResourceLimits = ResourceLimits{MaxOpenFiles: 10}
+
+	result, err = e.Run("-c", "exec 2>/dev/null; for i in {3..20}; do eval \"exec $i</dev/null\"; done; for ((i = 0; i < 500000; i++)); do :; done")
+	assert.NoError(t, err)
+	assert.Equal(t, ResourceLimitOpenFiles, result.ExceededResourceLimit)
+
+	// Programs within limits run as usual
+	e.ResourceLimits = ResourceLimits{MaxMemoryInBytes: 50 * 1024 * 1024, MaxCPUTimeInSeconds: 1, MaxOpenFiles: 10, MaxProcesses: 5}
+
+	result, err = e.Run("-c", "echo hey")
+	assert.NoError(t, err)
+	assert.Equal(t, "hey\n", string(result.Stdout))
+	assert.Equal(t, "", result.ExceededResourceLimit)
+}
+
+func TestResourceLimitsAppliedBeforeExecution(t *testing.T) {
+	e := NewExecutable("cat")
+	e.ResourceLimits = ResourceLimits{MaxOpenFiles: 50, MaxCPUTimeInSeconds: 5, MaxMemoryInBytes: 100 * 1024 * 1024}
+
+	for i := 0; i < 20; i++ {
+		result, err := e.Run("/proc/self/limits")
+		assert.NoError(t, err)
+		assert.Regexp(t, `Max open files\s+50\s+50`, string(result.Stdout))
+		assert.Regexp(t, `Max cpu time\s+5\s+6`, string(result.Stdout))
+		assert.Regexp(t, `Max address space\s+unlimited\s+unlimited`, string(result.Stdout)) // Memory is enforced by polling
+	}
+
+	// The limits are applied by re-executing the test binary, which needs Init
+	isInitCalled.Store(false)
+	defer isInitCalled.Store(true)
+
+	err := e.Start("/proc/self/limits")
+	assert.EqualError(t, err, "failed to apply resource limits: executable.Init() wasn't called at the start of main()")
+}
+
+func TestResourceUsage(t *testing.T) {
+	e := NewExecutable("./test_helpers/sleep_for.sh")
+
+	result, err := e.Run("0.2")
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, result.ResourceUsage.WallTime, 200*time.Millisecond)
+	assert.Less(t, result.ResourceUsage.WallTime, 2*time.Second)
+	assert.Greater(t, result.ResourceUsage.PeakMemoryInBytes, int64(0))
+	assert.Contains(t, result.ResourceUsage.String(), "wall time: ")
+
+	e = NewExecutable("bash")
+
+	result, err = e.Run("-c", "for ((i = 0; i < 500000; i++)); do :; done")
+	assert.NoError(t, err)
+	assert.Greater(t, result.ResourceUsage.UserCPUTime+result.ResourceUsage.SystemCPUTime, 100*time.Millisecond)
+}
+
+// skipIfNetworkIsolationUnavailable skips the test if the kernel doesn't allow unprivileged user namespaces. Example:
+// Ubuntu 24.04 restricts them using AppArmor.
+func skipIfNetworkIsolationUnavailable(t *testing.T) {
+	e := NewExecutable("true")
+	e.ShouldIsolateNetwork = true
+
+	result, err := e.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	if len(result.Warnings) > 0 {
+		t.Skipf("network isolation isn't available: %s", result.Warnings[0])
+	}
+}
+
+func TestNetworkIsolation(t *testing.T) {
+	skipIfNetworkIsolationUnavailable(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	e := NewExecutable("bash")
+	e.ShouldIsolateNetwork = true
+	e.SetEnv("HOST_ADDRESS", listener.Addr().String())
+
+	// Only the loopback interface is available, and it is up
+	result, err := e.Run("-c", `tail -n +3 /proc/net/dev | cut -d: -f1 | tr -d ' '; python3 -c "import socket; s=socket.socket(); s.bind(('127.0.0.1', 0)); s.listen(); socket.create_connection(s.getsockname()); print('loopback works')"`)
+	assert.NoError(t, err)
+	assert.Equal(t, "lo\nloopback works\n", string(result.Stdout))
+	assert.Equal(t, "", string(result.Stderr))
+	assert.Empty(t, result.Warnings)
+
+	// Servers outside the namespace aren't reachable, even on localhost
+	result, err = e.Run("-c", `python3 -c "import os, socket; host, port = os.environ['HOST_ADDRESS'].split(':'); socket.create_connection((host, int(port)))"`)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.ExitCode)
+	assert.Contains(t, string(result.Stderr), "Connection refused")
+
+	// The program's arguments, environment, file descriptors & exit code are passed through
+	result, err = e.Run("-c", `echo "$0 $HOST_ADDRESS ${TESTER_UTILS_CHILD_INIT:-unset}"; ls /proc/self/fd | wc -l; exit 3`)
+	assert.NoError(t, err)
+	assert.Equal(t, "bash "+listener.Addr().String()+" unset\n4\n", string(result.Stdout)) // stdin, stdout, stderr & the one ls opens
+	assert.Equal(t, 3, result.ExitCode)
+
+	e.ShouldUsePTY = true
+	result, err = e.Run("-c", "test -t 0 && echo tty")
+	assert.NoError(t, err)
+	assert.Equal(t, "tty\r\n", string(result.Stdout))
+}
+
+func TestNetworkIsolationFallback(t *testing.T) {
+	// Without Init, the tester binary can't be re-executed to set up the namespace
+	isInitCalled.Store(false)
+	defer isInitCalled.Store(true)
+
+	e := NewExecutable("bash")
+	e.ShouldIsolateNetwork = true
+
+	result, err := e.Run("-c", "echo hey")
+	assert.NoError(t, err)
+	assert.Equal(t, "hey\n", string(result.Stdout))
+	assert.Equal(t, []string{"Network isolation isn't available (executable.Init() wasn't called at the start of main()), the program was run without it."}, result.Warnings)
+}
+
+func TestResourceSampling(t *testing.T) {
+	e := NewExecutable("bash")
+	e.ResourceSamplingInterval = 20 * time.Millisecond
+
+	err := e.Start("-c", "echo ready; read; exec 3</dev/null 4</dev/null 5</dev/null; echo opened; read")
+	assert.NoError(t, err)
+
+	_, err = e.StdoutStream().ReadLine(time.Second)
+	assert.NoError(t, err)
+
+	before, err := e.SampleResources()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, before.Processes)
+	assert.Equal(t, 1, before.Threads)
+	assert.Greater(t, before.MemoryInBytes, int64(0))
+
+	_, err = e.StdinPipe.Write([]byte("\n"))
+	assert.NoError(t, err)
+	_, err = e.StdoutStream().ReadLine(time.Second)
+	assert.NoError(t, err)
+
+	after, err := e.SampleResources()
+	assert.NoError(t, err)
+	assert.Equal(t, before.OpenFileDescriptors+3, after.OpenFileDescriptors)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.NotEmpty(t, e.ResourceSamples())
+
+	result, err := e.Wait() // Closes stdin, which makes the program exit
+	assert.NoError(t, err)
+
+	samples := result.ResourceSamples
+	if assert.Greater(t, len(samples), 2) {
+		assert.Equal(t, after.OpenFileDescriptors, samples[len(samples)-1].OpenFileDescriptors)
+		assert.Contains(t, samples[len(samples)-1].String(), "processes: 1, threads: 1, open fds: ")
+
+		for i := 1; i < len(samples); i++ {
+			assert.Greater(t, samples[i].Timestamp, samples[i-1].Timestamp)
+		}
+	}
+
+	// Sampling is opt-in
+	e.ResourceSamplingInterval = 0
+	result, err = e.Run("-c", "sleep 0.1")
+	assert.NoError(t, err)
+	assert.Empty(t, result.ResourceSamples)
+
+	_, err = e.SampleResources()
+	assert.EqualError(t, err, "program is not running")
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("CODECRAFTERS_TEST_VAR", "tester")
+	t.Setenv("TEST_INHERITED_VAR", "inherited")
+
+	printEnv := "echo \"${PORT-unset} ${TEST_INHERITED_VAR-unset} ${CODECRAFTERS_TEST_VAR-unset}\""
+
+	// The tester's environment is inherited by default
+	e := NewExecutable("bash")
+	result, err := e.Run("-c", printEnv)
+	assert.NoError(t, err)
+	assert.Equal(t, "unset inherited tester\n", string(result.Stdout))
+
+	e.SetEnv("PORT", "6379")
+	e.SetEnv("TEST_INHERITED_VAR", "overridden")
+	e.UnsetEnvWithPrefix("CODECRAFTERS_")
+	result, err = e.Run("-c", printEnv)
+	assert.NoError(t, err)
+	assert.Equal(t, "6379 overridden unset\n", string(result.Stdout))
+
+	// Changes are preserved across clones
+	clone := e.Clone()
+	clone.UnsetEnv("PORT")
+	result, err = clone.Run("-c", printEnv)
+	assert.NoError(t, err)
+	assert.Equal(t, "unset overridden unset\n", string(result.Stdout))
+
+	// Changes to the clone don't affect the original
+	result, err = e.Run("-c", printEnv)
+	assert.NoError(t, err)
+	assert.Equal(t, "6379 overridden unset\n", string(result.Stdout))
+
+	e.ClearEnv()
+	e.SetEnv("PORT", "6380")
+	result, err = e.Run("-c", printEnv)
+	assert.NoError(t, err)
+	assert.Equal(t, "6380 unset unset\n", string(result.Stdout))
+}
+
+func TestOutputStream(t *testing.T) {
+	e := NewExecutable("bash")
+
+	err := e.Start("-c", "echo first; echo second; printf 'prompt> '; sleep 0.2; printf 'abcdef'; echo error 1>&2; read line; echo \"got $line\"")
+	assert.NoError(t, err)
+
+	stdout := e.StdoutStream()
+
+	line, err := stdout.ReadLine(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", line)
+
+	output, err := stdout.ReadUntilMatch(regexp.MustCompile(`\w+> `), time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "second\nprompt> ", string(output))
+
+	// Reads that time out don't consume any output
+	_, err = stdout.ReadBytes(6, 50*time.Millisecond)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Contains(t, err.Error(), "timed out after 50ms waiting for 6 bytes on stdout")
+
+	output, err = stdout.ReadBytes(3, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(output))
+
+	output, err = stdout.ReadUntil([]byte("f"), time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "def", string(output))
+
+	line, err = e.StderrStream().ReadLine(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "error", line)
+
+	e.StdinPipe.Write([]byte("input\n"))
+
+	line, err = stdout.ReadLine(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "got input", line)
+
+	// Reading past the end of the output returns EOF
+	_, err = stdout.ReadLine(time.Second)
+	assert.ErrorIs(t, err, io.EOF)
+
+	// The result still contains all output
+	result, err := e.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, "first\nsecond\nprompt> abcdefgot input\n", string(result.Stdout))
+	assert.Nil(t, e.StdoutStream())
+}
+
+func TestOutputTimeline(t *testing.T) {
+	e := NewExecutable("bash")
+
+	result, err := e.Run("-c", "echo first; sleep 0.1; echo second 1>&2; sleep 0.1; echo third")
+	assert.NoError(t, err)
+	assert.Equal(t, "first\nthird\n", string(result.Stdout))
+	assert.Equal(t, "second\n", string(result.Stderr))
+
+	if assert.Len(t, result.OutputTimeline, 3) {
+		assert.Equal(t, OutputChunk{Stream: "stdout", Data: []byte("first\n"), Timestamp: result.OutputTimeline[0].Timestamp}, result.OutputTimeline[0])
+		assert.Equal(t, OutputChunk{Stream: "stderr", Data: []byte("second\n"), Timestamp: result.OutputTimeline[1].Timestamp}, result.OutputTimeline[1])
+		assert.Equal(t, OutputChunk{Stream: "stdout", Data: []byte("third\n"), Timestamp: result.OutputTimeline[2].Timestamp}, result.OutputTimeline[2])
+
+		// Timestamps are taken when output is read, which can happen a little after it's written
+		assert.GreaterOrEqual(t, result.OutputTimeline[1].Timestamp-result.OutputTimeline[0].Timestamp, 50*time.Millisecond)
+		assert.GreaterOrEqual(t, result.OutputTimeline[2].Timestamp-result.OutputTimeline[1].Timestamp, 50*time.Millisecond)
+	}
+}
+
+func TestSendSignal(t *testing.T) {
+	e := NewExecutable("bash")
+
+	err := e.SendSignal(syscall.SIGHUP)
+	assert.EqualError(t, err, "program is not running")
+
+	err = e.Start("-c", "trap 'echo reloaded' HUP; trap 'echo stopping; exit 0' TERM; while true; do sleep 0.01; done")
+	assert.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	err = e.SendSignal(syscall.SIGHUP)
+	assert.NoError(t, err)
+
+	line, err := e.StdoutStream().ReadLine(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "reloaded", line)
+
+	// Exits within the grace period, so no error
+	err = e.Stop(time.Second)
+	assert.NoError(t, err)
+}
+
+func TestSendSignalToProcessGroup(t *testing.T) {
+	e := NewExecutable("bash")
+
+	err := e.Start("-c", "while true; do echo tick; sleep 0.01; done")
+	assert.NoError(t, err)
+
+	_, err = e.StdoutStream().ReadLine(time.Second)
+	assert.NoError(t, err)
+
+	err = e.SendSignalToProcessGroup(syscall.SIGSTOP)
+	assert.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond) // Let any output that was in flight arrive
+	_, err = e.StdoutStream().ReadUntilMatch(regexp.MustCompile(`(tick\n)*$`), time.Second)
+	assert.NoError(t, err)
+
+	_, err = e.StdoutStream().ReadLine(100 * time.Millisecond)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded, "Expected no output while stopped")
+
+	err = e.SendSignalToProcessGroup(syscall.SIGCONT)
+	assert.NoError(t, err)
+
+	line, err := e.StdoutStream().ReadLine(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "tick", line)
+
+	err = e.Kill()
+	assert.NoError(t, err)
+}
+
+func TestPauseAndResume(t *testing.T) {
+	e := NewExecutable("bash")
+
+	err := e.Pause()
+	assert.EqualError(t, err, "program is not running")
+
+	// Time spent paused doesn't count towards the timeout
+	e.TimeoutInMilliseconds = 300
+	err = e.Start("-c", "sleep 0.2; echo done")
+	assert.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, e.Pause())
+	time.Sleep(400 * time.Millisecond)
+	assert.NoError(t, e.Resume())
+
+	result, err := e.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, "done\n", string(result.Stdout))
+
+	// The timeout still applies once resumed
+	startTime := time.Now()
+	err = e.Start("-c", "sleep 10")
+	assert.NoError(t, err)
+
+	assert.NoError(t, e.Pause())
+	time.Sleep(200 * time.Millisecond)
+	assert.NoError(t, e.Resume())
+
+	result, err = e.Wait()
+	assert.EqualError(t, err, "execution timed out")
+	assert.True(t, result.TimedOut)
+	assert.GreaterOrEqual(t, time.Since(startTime), 500*time.Millisecond)
+
+	// A paused program can still be stopped gracefully
+	e.TimeoutInMilliseconds = 10 * 1000
+	err = e.Start("-c", "trap 'echo stopping; exit 0' TERM; echo started; while true; do sleep 0.01; done")
+	assert.NoError(t, err)
+
+	_, err = e.StdoutStream().ReadLine(time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, e.Pause())
+	assert.NoError(t, e.Stop(time.Second))
+}
+
+func TestStopEscalatesToSIGKILL(t *testing.T) {
+	e := NewExecutable("bash")
+
+	err := e.Start("-c", "trap '' SIGTERM SIGINT; sleep 60")
+	assert.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	startTime := time.Now()
+	err = e.Stop(200 * time.Millisecond)
+	assert.EqualError(t, err, "program failed to exit in 200 milliseconds after receiving sigterm")
+	assert.Less(t, time.Since(startTime), time.Second)
+	assert.False(t, e.isRunning())
+}
+
+func TestRunContext(t *testing.T) {
+	e := NewExecutable("bash")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	result, err := e.RunContext(ctx, "-c", "sleep 10 & wait")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assertErrorContains(t, err, "execution cancelled")
+	assert.Equal(t, syscall.SIGKILL, result.TerminationSignal)
+	assert.Less(t, time.Since(startTime), time.Second)
+	assert.False(t, e.isRunning())
+
+	// Cancelling while waiting
+	ctx, cancel = context.WithCancel(context.Background())
+
+	err = e.StartContext(ctx, "-c", "sleep 10")
+	assert.NoError(t, err)
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	_, err = e.WaitContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, e.isRunning())
+
+	// The executable can be used again after a cancellation
+	result, err = e.RunContext(context.Background(), "-c", "echo hey")
+	assert.NoError(t, err)
+	assert.Equal(t, "hey\n", string(result.Stdout))
+}
+
+func TestConcurrentKillAndWait(t *testing.T) {
+	e := NewExecutable("bash")
+
+	err := e.Start("-c", "echo started; sleep 10")
+	assert.NoError(t, err)
+
+	var waitGroup sync.WaitGroup
+	results := make([]ExecutableResult, 3)
+
+	for i := range results {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			results[i], _ = e.Wait()
+		}()
+	}
+
+	go e.HasExited()
+	go e.StdoutStream()
+
+	_, err = e.StdoutStream().ReadLine(time.Second)
+	assert.NoError(t, err)
+
+	err = e.Kill()
+	assert.NoError(t, err)
+
+	waitGroup.Wait()
+
+	// Every waiter receives the same result
+	for _, result := range results {
+		assert.Equal(t, "started\n", string(result.Stdout))
+		assert.Equal(t, syscall.SIGTERM, result.TerminationSignal)
+	}
+
+	assert.False(t, e.isRunning())
+	assert.False(t, e.HasExited())
+}
+
+func assertProcessExited(t *testing.T, pid int) {
+	assert.Eventually(t, func() bool {
+		stat, err := readProcStat(pid)
+		return err != nil || stat.state == 'Z'
+	}, time.Second, 10*time.Millisecond, "Expected pid %d to have exited", pid)
+}
+
+func TestKillLeakedProcesses(t *testing.T) {
+	e := NewExecutable("bash")
+
+	result, err := e.Run("-c", "echo ok")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok\n", string(result.Stdout))
+	assert.Empty(t, e.KillLeakedProcesses())
+
+	// Background processes that outlive the program
+	portFilePath := filepath.Join(t.TempDir(), "port.txt")
+	_, err = e.Run("-c", `sleep 60 >/dev/null 2>&1 & python3 -c "import socket, time; s = socket.socket(); s.bind(('127.0.0.1', 0)); s.listen(); print(s.getsockname()[1], flush=True); time.sleep(60)" > "$0" 2>&1 &`, portFilePath)
+	assert.NoError(t, err)
+
+	var port []byte
+	assert.Eventually(t, func() bool {
+		port, _ = os.ReadFile(portFilePath)
+		return len(port) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	leakedProcesses := e.KillLeakedProcesses()
+	if assert.Len(t, leakedProcesses, 2) {
+		assert.Equal(t, "sleep 60", leakedProcesses[0].Command)
+		assert.Empty(t, leakedProcesses[0].ListeningAddresses)
+		assert.Contains(t, leakedProcesses[1].Command, "python3 -c")
+		assert.Equal(t, []string{"127.0.0.1:" + strings.TrimSpace(string(port))}, leakedProcesses[1].ListeningAddresses)
+		assert.Equal(t, fmt.Sprintf("pid %d (sleep 60)", leakedProcesses[0].Pid), leakedProcesses[0].String())
+
+		assertProcessExited(t, leakedProcesses[0].Pid)
+		assertProcessExited(t, leakedProcesses[1].Pid)
+	}
+
+	assert.Empty(t, e.KillLeakedProcesses())
+
+	// Processes that left the process group before the program exited
+	_, err = e.Run("-c", "setsid sleep 63 >/dev/null 2>&1 </dev/null &")
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond) // Let setsid run
+
+	leakedProcesses = e.KillLeakedProcesses()
+	if assert.Len(t, leakedProcesses, 1) {
+		assert.Equal(t, "sleep 63", leakedProcesses[0].Command)
+		assertProcessExited(t, leakedProcesses[0].Pid)
+	}
+}
+
+func TestKillCleansUpLeakedProcesses(t *testing.T) {
+	e := NewExecutable("bash")
+
+	// One child ignores SIGTERM, the other leaves the process group
+	err := e.Start("-c", `(trap '' TERM; exec sleep 61) >/dev/null 2>&1 & setsid sleep 62 >/dev/null 2>&1 & echo started; sleep 60`)
+	assert.NoError(t, err)
+
+	_, err = e.StdoutStream().ReadLine(time.Second)
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond) // Let setsid run
+
+	err = e.Kill()
+	assert.NoError(t, err)
+
+	leakedProcesses := e.KillLeakedProcesses()
+	commands := []string{}
+	for _, leakedProcess := range leakedProcesses {
+		commands = append(commands, leakedProcess.Command)
+		assertProcessExited(t, leakedProcess.Pid)
+	}
+
+	assert.ElementsMatch(t, []string{"sleep 61", "sleep 62"}, commands)
+}
+
+func TestParseProcNetAddress(t *testing.T) {
+	address, err := parseProcNetAddress("0100007F:18EB")
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:6379", address)
+
+	address, err = parseProcNetAddress("00000000000000000000000001000000:1F90")
+	assert.NoError(t, err)
+	assert.Equal(t, "[::1]:8080", address)
+
+	_, err = parseProcNetAddress("invalid")
+	assert.Error(t, err)
+}
+
+func TestTerminationStatus(t *testing.T) {
+	e := NewExecutable("bash")
+
+	result, err := e.Run("-c", "exit 3")
+	assert.NoError(t, err)
+	assert.True(t, result.ExitedNormally())
+	assert.Equal(t, 3, result.ExitCode)
+	assert.Equal(t, syscall.Signal(0), result.TerminationSignal)
+	assert.Equal(t, "", result.TerminationExplanation())
+
+	result, err = e.Run("-c", "kill -SEGV $$")
+	assert.NoError(t, err)
+	assert.False(t, result.ExitedNormally())
+	assert.Equal(t, -1, result.ExitCode)
+	assert.Equal(t, syscall.SIGSEGV, result.TerminationSignal)
+	assert.Equal(t, "SIGSEGV", result.TerminationSignalName)
+	assert.Contains(t, result.TerminationExplanation(), "segmentation fault")
+
+	result, err = e.Run("-c", "kill -USR1 $$")
+	assert.NoError(t, err)
+	assert.Equal(t, "SIGUSR1", result.TerminationSignalName)
+	assert.Equal(t, "Program was terminated by SIGUSR1.", result.TerminationExplanation())
+
+	e.TimeoutInMilliseconds = 50
+	result, err = e.Run("-c", "sleep 10")
+	assert.EqualError(t, err, "execution timed out")
+	assert.True(t, result.TimedOut)
+	assert.False(t, result.ExitedNormally())
+	assert.Contains(t, result.TerminationExplanation(), "timeout")
+}
+
+func TestStartAndWaitForTCPPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close() // Only used to find a free port
+
+	e := NewExecutable("python3")
+
+	err = e.StartAndWaitForTCPPort(address, 5*time.Second, "-c", fmt.Sprintf(`
+import socket, time
+time.sleep(0.2)
+s = socket.socket()
+s.setsockopt(socket.SOL_SOCKET, socket.SO_REUSEADDR, 1)
+s.bind(("127.0.0.1", %s))
+s.listen()
+time.sleep(10)
+`, strings.Split(address, ":")[1]))
+	assert.NoError(t, err)
+	assert.NoError(t, e.Kill())
+
+	// Program crashes before binding
+	e = NewExecutable("bash")
+
+	err = e.StartAndWaitForTCPPort(address, 5*time.Second, "-c", "echo starting; echo 'bind failed' 1>&2; exit 2")
+	assert.EqualError(t, err, fmt.Sprintf("program exited (exit code 2) before accepting connections on %s\nProgram's stdout:\nstarting\nProgram's stderr:\nbind failed", address))
+	assert.False(t, e.isRunning())
+
+	// Program never binds
+	err = e.StartAndWaitForTCPPort(address, 200*time.Millisecond, "-c", "echo listening; sleep 10")
+	assertErrorContains(t, err, "timed out after 200ms waiting for program to accept connections on "+address)
+	assertErrorContains(t, err, "Program's stdout:\nlistening")
+
+	// Program closes its output without exiting
+	startTime := time.Now()
+	err = e.StartAndWaitForTCPPort(address, 200*time.Millisecond, "-c", "exec >/dev/null 2>&1; sleep 30")
+	assertErrorContains(t, err, "timed out after 200ms waiting for program to accept connections on "+address)
+	assert.Less(t, time.Since(startTime), 3*time.Second)
+
+	// Program exits, but a process it started holds on to its output
+	startTime = time.Now()
+	err = e.StartAndWaitForTCPPort(address, 5*time.Second, "-c", "echo starting; sleep 30 & exit 2")
+	assert.EqualError(t, err, fmt.Sprintf("program exited (exit code 2) before accepting connections on %s\nProgram's stdout:\nstarting", address))
+	assert.Less(t, time.Since(startTime), time.Second)
+
+	// Program is terminated by a signal
+	err = e.StartAndWaitForTCPPort(address, 5*time.Second, "-c", "kill -SEGV $$")
+	assert.EqualError(t, err, fmt.Sprintf("program exited (terminated by SIGSEGV) before accepting connections on %s\nProgram didn't print any output.", address))
+	assert.False(t, e.isRunning())
+}
+
+func TestStartAndWaitForOutputLine(t *testing.T) {
+	e := NewExecutable("bash")
+
+	line, err := e.StartAndWaitForOutputLine(regexp.MustCompile(`Listening on \d+`), 5*time.Second, "-c", "echo starting; sleep 0.1; echo 'Listening on 6379' 1>&2; sleep 10")
+	assert.NoError(t, err)
+	assert.Equal(t, "Listening on 6379", line)
+
+	// Lines printed before waiting are included
+	line, err = e.WaitForOutputLine(regexp.MustCompile(`starting`), time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "starting", line)
+	assert.NoError(t, e.Kill())
+
+	// Program exits before printing the line
+	_, err = e.StartAndWaitForOutputLine(regexp.MustCompile(`Listening on`), 5*time.Second, "-c", "echo starting; echo 'config error' 1>&2; exit 1")
+	assertErrorContains(t, err, "program exited (exit code 1) before printing a line matching \"Listening on\"\nLines received:")
+	assertErrorContains(t, err, "[stdout] starting")
+	assertErrorContains(t, err, "[stderr] config error")
+	assert.False(t, e.isRunning())
+
+	// Program never prints the line
+	_, err = e.StartAndWaitForOutputLine(regexp.MustCompile(`Listening on`), 200*time.Millisecond, "-c", "sleep 10")
+	assert.EqualError(t, err, "timed out after 200ms waiting for program to print a line matching \"Listening on\"\nProgram didn't print any output.")
+	assert.False(t, e.isRunning())
+
+	// Lines printed right before exiting are found, including output without a trailing newline
+	for i := 0; i < 20; i++ {
+		line, err = e.StartAndWaitForOutputLine(regexp.MustCompile(`Listening on`), 5*time.Second, "-c", "echo 'Listening on 1234'; exit 0")
+		assert.NoError(t, err)
+		assert.Equal(t, "Listening on 1234", line)
+		e.Wait()
+
+		line, err = e.StartAndWaitForOutputLine(regexp.MustCompile(`Listening on`), 5*time.Second, "-c", "printf 'Listening on 1234'; exit 0")
+		assert.NoError(t, err)
+		assert.Equal(t, "Listening on 1234", line)
+
+		e.Wait()
+	}
+
+	// Program closes its output without exiting
+	startTime := time.Now()
+	_, err = e.StartAndWaitForOutputLine(regexp.MustCompile(`Listening on`), 200*time.Millisecond, "-c", "echo starting; exec >/dev/null 2>&1; sleep 30")
+	assert.EqualError(t, err, "timed out after 200ms waiting for program to print a line matching \"Listening on\"\nLines received:\n[stdout] starting")
+	assert.Less(t, time.Since(startTime), 3*time.Second)
+	assert.False(t, e.isRunning())
+}
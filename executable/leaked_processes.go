@@ -0,0 +1,121 @@
+package executable
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// LeakedProcess is a process started by the program that was still running after the program exited (or was killed).
+// Example: a background worker, or a server started with --daemonize.
+type LeakedProcess struct {
+	Pid     int
+	Command string
+
+	// ListeningAddresses are the TCP addresses the process is listening on. Example: "127.0.0.1:6379"
+	ListeningAddresses []string
+}
+
+// String returns a short description of the process. Example: "pid 1234 (redis-server), listening on 127.0.0.1:6379"
+func (p LeakedProcess) String() string {
+	description := fmt.Sprintf("pid %d (%s)", p.Pid, p.Command)
+
+	if len(p.ListeningAddresses) > 0 {
+		description += ", listening on " + strings.Join(p.ListeningAddresses, ", ")
+	}
+
+	return description
+}
+
+// processIdentity identifies a process, even if its pid is reused later.
+type processIdentity struct {
+	pid            int
+	startTimeTicks uint64
+}
+
+// processesStartedByExecutables contains the processes started by every Executable. The tester's other children were
+// usually adopted once orphaned, see becomeChildSubreaper.
+var processesStartedByExecutables = struct {
+	mutex      sync.Mutex
+	identities map[processIdentity]bool
+}{identities: map[processIdentity]bool{}}
+
+// startingProcessesMutex is read-locked while a process is being started and locked while looking for leaked processes,
+// so that a process isn't mistaken for an adopted one before it's added to processesStartedByExecutables.
+var startingProcessesMutex sync.RWMutex
+
+func addProcessStartedByExecutable(identity processIdentity) {
+	processesStartedByExecutables.mutex.Lock()
+	defer processesStartedByExecutables.mutex.Unlock()
+
+	processesStartedByExecutables.identities[identity] = true
+}
+
+func isProcessStartedByExecutable(identity processIdentity) bool {
+	processesStartedByExecutables.mutex.Lock()
+	defer processesStartedByExecutables.mutex.Unlock()
+
+	return processesStartedByExecutables.identities[identity]
+}
+
+// KillLeakedProcesses kills processes started by the program that are still running, even though the program has
+// exited. Leaked processes that Kill or Stop already cleaned up are included in the result. Only processes started
+// since the last call are considered.
+//
+// Processes are found if they're in the program's process group, or are descendants of such processes. Processes that
+// left the process group (using setsid, for example) are found once they're orphaned, since the tester adopts them (see
+// becomeChildSubreaper). Orphans of other programs the tester started can be included too, they're leaked as well.
+func (e *Executable) KillLeakedProcesses() []LeakedProcess {
+	e.mutex.Lock()
+	startedProcesses := e.startedProcesses
+	knownDescendants := e.knownDescendants
+	killedLeakedProcesses := e.killedLeakedProcesses
+
+	e.startedProcesses = nil
+	e.knownDescendants = nil
+	e.killedLeakedProcesses = nil
+	e.mutex.Unlock()
+
+	return append(killedLeakedProcesses, findAndKillLeakedProcesses(startedProcesses, knownDescendants)...)
+}
+
+// recordDescendants is called before a program is killed, so that descendants which left the program's process group
+// can still be found once they're orphaned.
+func (e *Executable) recordDescendants(pid int) {
+	descendants := listDescendantIdentities(pid)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.knownDescendants = append(e.knownDescendants, descendants...)
+}
+
+// killLeakedProcessesAfterStop is called once a program has been stopped. Leaked processes are killed right away so that
+// they don't hold on to resources like ports, and are reported when KillLeakedProcesses is called.
+func (e *Executable) killLeakedProcessesAfterStop() {
+	e.mutex.Lock()
+	startedProcesses := e.startedProcesses
+	knownDescendants := e.knownDescendants
+	e.mutex.Unlock()
+
+	leakedProcesses := findAndKillLeakedProcesses(startedProcesses, knownDescendants)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.killedLeakedProcesses = append(e.killedLeakedProcesses, leakedProcesses...)
+}
+
+// killKnownDescendants kills the descendants recorded using recordDescendants, if they're still running.
+func (e *Executable) killKnownDescendants() {
+	e.mutex.Lock()
+	knownDescendants := e.knownDescendants
+	e.mutex.Unlock()
+
+	for _, descendant := range knownDescendants {
+		if readProcessIdentity(descendant.pid) == descendant {
+			signalProcess(descendant.pid, syscall.SIGKILL)
+		}
+	}
+}
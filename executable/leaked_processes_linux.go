@@ -0,0 +1,183 @@
+package executable
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var becomeChildSubreaperOnce sync.Once
+
+// becomeChildSubreaper makes orphaned descendants of the tester's programs its children, instead of init's (see
+// PR_SET_CHILD_SUBREAPER in prctl(2)). That's how processes which left a program's process group are found once the
+// program has exited.
+func becomeChildSubreaper() {
+	becomeChildSubreaperOnce.Do(func() {
+		unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0)
+	})
+}
+
+// isAdoptedProcess returns true if the process is a child of the tester that it didn't start using an Executable, i.e.
+// one that was orphaned. Processes in the tester's own process group were started by the tester in some other way.
+func isAdoptedProcess(stat procStat) bool {
+	return stat.ppid == os.Getpid() &&
+		stat.pgid != syscall.Getpgrp() &&
+		!isProcessStartedByExecutable(processIdentity{pid: stat.pid, startTimeTicks: stat.startTimeTicks})
+}
+
+// reapAdoptedProcess reaps a process the tester adopted once it has exited. Nothing else waits for these.
+func reapAdoptedProcess(stat procStat) {
+	if stat.state == 'Z' && isAdoptedProcess(stat) {
+		unix.Wait4(stat.pid, nil, unix.WNOHANG, nil)
+	}
+}
+
+func readProcessIdentity(pid int) processIdentity {
+	stat, err := readProcStat(pid)
+	if err != nil {
+		return processIdentity{pid: pid}
+	}
+
+	return processIdentity{pid: pid, startTimeTicks: stat.startTimeTicks}
+}
+
+// listDescendantIdentities returns pid's descendants, and members of its process group.
+func listDescendantIdentities(pid int) []processIdentity {
+	stats, err := listProcStats()
+	if err != nil {
+		return nil
+	}
+
+	identities := []processIdentity{}
+	for _, stat := range findProcessTree(stats, func(stat procStat) bool { return stat.pid == pid || stat.pgid == pid }) {
+		identities = append(identities, processIdentity{pid: stat.pid, startTimeTicks: stat.startTimeTicks})
+	}
+
+	return identities
+}
+
+// findProcessTree returns the processes for which isRoot returns true, along with all of their descendants. Zombies
+// aren't included.
+func findProcessTree(stats []procStat, isRoot func(stat procStat) bool) []procStat {
+	includedPids := map[int]bool{}
+
+	for _, stat := range stats {
+		if isRoot(stat) {
+			includedPids[stat.pid] = true
+		}
+	}
+
+	// Keep adding children until no more are found
+	for foundNewPids := true; foundNewPids; {
+		foundNewPids = false
+
+		for _, stat := range stats {
+			if !includedPids[stat.pid] && includedPids[stat.ppid] {
+				includedPids[stat.pid] = true
+				foundNewPids = true
+			}
+		}
+	}
+
+	tree := []procStat{}
+	for _, stat := range stats {
+		if includedPids[stat.pid] && stat.state != 'Z' {
+			tree = append(tree, stat)
+		}
+	}
+
+	return tree
+}
+
+func findAndKillLeakedProcesses(startedProcesses []processIdentity, knownDescendants []processIdentity) []LeakedProcess {
+	if len(startedProcesses) == 0 && len(knownDescendants) == 0 {
+		return []LeakedProcess{}
+	}
+
+	startingProcessesMutex.Lock()
+	stats, err := listProcStats()
+	startingProcessesMutex.Unlock()
+
+	if err != nil {
+		return []LeakedProcess{}
+	}
+
+	for _, stat := range stats {
+		reapAdoptedProcess(stat)
+	}
+
+	leakedStats := findProcessTree(stats, func(stat procStat) bool {
+		if stat.pid == os.Getpid() {
+			return false
+		}
+
+		// The process group outlives the program if other processes are still in it. Processes that started before the
+		// program can't be its descendants, this guards against the process group ID being reused.
+		for _, startedProcess := range startedProcesses {
+			if stat.pgid == startedProcess.pid && stat.startTimeTicks >= startedProcess.startTimeTicks {
+				return true
+			}
+		}
+
+		// Orphans are adopted by the tester, see becomeChildSubreaper
+		for _, startedProcess := range startedProcesses {
+			if isAdoptedProcess(stat) && stat.startTimeTicks >= startedProcess.startTimeTicks {
+				return true
+			}
+		}
+
+		for _, descendant := range knownDescendants {
+			if stat.pid == descendant.pid && stat.startTimeTicks == descendant.startTimeTicks {
+				return true
+			}
+		}
+
+		return false
+	})
+
+	sort.Slice(leakedStats, func(i, j int) bool { return leakedStats[i].pid < leakedStats[j].pid })
+
+	leakedProcesses := []LeakedProcess{}
+
+	// Details are read before killing anything, since they're gone once the process exits
+	for _, stat := range leakedStats {
+		command := readCommandLine(stat.pid)
+		if command == "" {
+			continue // The process is already exiting, it was probably sent a signal along with the program
+		}
+
+		leakedProcesses = append(leakedProcesses, LeakedProcess{
+			Pid:                stat.pid,
+			Command:            command,
+			ListeningAddresses: listListeningAddresses(stat.pid),
+		})
+	}
+
+	for _, leakedProcess := range leakedProcesses {
+		syscall.Kill(leakedProcess.Pid, syscall.SIGKILL)
+	}
+
+	// Wait for the processes to exit, so that the resources they hold (like ports) are free once we return
+	deadline := time.Now().Add(time.Second)
+	for _, leakedProcess := range leakedProcesses {
+		for time.Now().Before(deadline) {
+			stat, err := readProcStat(leakedProcess.Pid)
+			if err != nil {
+				break
+			}
+
+			if stat.state == 'Z' {
+				reapAdoptedProcess(stat)
+				break
+			}
+
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	return leakedProcesses
+}
@@ -0,0 +1,15 @@
+package executable
+
+func readProcessIdentity(pid int) processIdentity {
+	return processIdentity{pid: pid}
+}
+
+func listDescendantIdentities(pid int) []processIdentity {
+	return nil
+}
+
+func findAndKillLeakedProcesses(startedProcesses []processIdentity, knownDescendants []processIdentity) []LeakedProcess {
+	return []LeakedProcess{}
+}
+
+func becomeChildSubreaper() {}
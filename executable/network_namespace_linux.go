@@ -0,0 +1,55 @@
+package executable
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// isolateNetwork makes cmd run in new user & network namespaces, where only the loopback interface is available. A new
+// network namespace starts with the loopback interface down, so cmd must also be wrapped using wrapWithChildInit with
+// ShouldBringUpLoopback set.
+func isolateNetwork(cmd *exec.Cmd) error {
+	// The program keeps its user & group ID. CAP_NET_ADMIN is needed to bring up the loopback interface, it is dropped
+	// before the program is executed.
+	cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+	cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	cmd.SysProcAttr.AmbientCaps = []uintptr{unix.CAP_NET_ADMIN}
+
+	return nil
+}
+
+// isNetworkNamespaceUnsupportedError returns true if err is what starting a process in new namespaces returns when
+// the kernel doesn't allow it. Example: unprivileged user namespaces are disabled.
+func isNetworkNamespaceUnsupportedError(err error) bool {
+	return errors.Is(err, syscall.EPERM) ||
+		errors.Is(err, syscall.EACCES) ||
+		errors.Is(err, syscall.EINVAL) ||
+		errors.Is(err, syscall.ENOSPC) ||
+		errors.Is(err, syscall.EUSERS)
+}
+
+func bringUpLoopbackInterface() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifreq, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifreq); err != nil {
+		return err
+	}
+
+	ifreq.SetUint16(ifreq.Uint16() | unix.IFF_UP)
+
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifreq)
+}
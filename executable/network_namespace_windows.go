@@ -0,0 +1,14 @@
+package executable
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func isolateNetwork(cmd *exec.Cmd) error {
+	return errors.New("network isolation is not supported on Windows")
+}
+
+func isNetworkNamespaceUnsupportedError(err error) bool {
+	return false
+}
@@ -0,0 +1,39 @@
+package executable
+
+import "sync"
+
+// outputLine is a line printed by the program, as split by the linewriter relay.
+type outputLine struct {
+	streamName string
+	text       string
+}
+
+// outputLines records the lines a program printed on stdout & stderr, in the order they were relayed.
+type outputLines struct {
+	mutex sync.Mutex
+	lines []outputLine
+
+	// changedChan is closed (and replaced) whenever a line is added
+	changedChan chan bool
+}
+
+func newOutputLines() *outputLines {
+	return &outputLines{changedChan: make(chan bool)}
+}
+
+func (o *outputLines) add(streamName string, text string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.lines = append(o.lines, outputLine{streamName: streamName, text: text})
+	close(o.changedChan)
+	o.changedChan = make(chan bool)
+}
+
+// snapshot returns the lines recorded so far, and a channel that is closed when more lines are added.
+func (o *outputLines) snapshot() ([]outputLine, chan bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return o.lines[:len(o.lines):len(o.lines)], o.changedChan
+}
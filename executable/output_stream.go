@@ -0,0 +1,204 @@
+package executable
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// OutputStream holds the output a running program writes to stdout or stderr, and allows reading it incrementally.
+//
+// Reads start where the previous successful read ended. A read that times out doesn't consume anything, so the same
+// output can be read again using a different method.
+//
+// Errors returned from reads wrap os.ErrDeadlineExceeded if the timeout was hit, and io.EOF if the program closed the
+// stream (or exited) before the expected output was received.
+type OutputStream struct {
+	// name is used in error messages. Example: "stdout"
+	name string
+
+	mutex      sync.Mutex
+	data       []byte
+	readOffset int
+	isClosed   bool
+
+	// These are only set if the relay had to discard output
+	isTruncated bool
+	totalBytes  int64
+
+	// changedChan is closed (and replaced) whenever data is written or the stream is closed
+	changedChan chan bool
+}
+
+func newOutputStream(name string) *OutputStream {
+	return &OutputStream{
+		name:        name,
+		data:        []byte{},
+		changedChan: make(chan bool),
+	}
+}
+
+// Write appends to the stream. It is called by the IO relay, not meant to be used by tests.
+func (s *OutputStream) Write(bytes []byte) (n int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data = append(s.data, bytes...)
+	s.notifyChanged()
+
+	return len(bytes), nil
+}
+
+func (s *OutputStream) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.isClosed = true
+	s.notifyChanged()
+}
+
+func (s *OutputStream) markTruncated(totalBytes int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.isTruncated = true
+	s.totalBytes = totalBytes
+}
+
+// truncationInfo returns whether output was discarded, and how many bytes were written in total (including discarded ones)
+func (s *OutputStream) truncationInfo() (isTruncated bool, totalBytes int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isTruncated {
+		return true, s.totalBytes
+	}
+
+	return false, int64(len(s.data))
+}
+
+// notifyChanged must be called with mutex held
+func (s *OutputStream) notifyChanged() {
+	close(s.changedChan)
+	s.changedChan = make(chan bool)
+}
+
+// Bytes returns everything written to the stream so far, including output that has already been read.
+func (s *OutputStream) Bytes() []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]byte{}, s.data...)
+}
+
+// ReadLine reads the next line, and returns it without the trailing newline.
+func (s *OutputStream) ReadLine(timeout time.Duration) (string, error) {
+	line, err := s.ReadUntil([]byte("\n"), timeout)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes.TrimSuffix(line[:len(line)-1], []byte("\r"))), nil
+}
+
+// ReadUntil reads until delimiter is found, and returns the output including the delimiter.
+func (s *OutputStream) ReadUntil(delimiter []byte, timeout time.Duration) ([]byte, error) {
+	return s.read(fmt.Sprintf("%q", delimiter), timeout, func(unread []byte) int {
+		index := bytes.Index(unread, delimiter)
+		if index == -1 {
+			return -1
+		}
+
+		return index + len(delimiter)
+	})
+}
+
+// ReadUntilMatch reads until the output matches pattern, and returns the output up to the end of the match.
+func (s *OutputStream) ReadUntilMatch(pattern *regexp.Regexp, timeout time.Duration) ([]byte, error) {
+	return s.read(fmt.Sprintf("output matching %q", pattern.String()), timeout, func(unread []byte) int {
+		location := pattern.FindIndex(unread)
+		if location == nil {
+			return -1
+		}
+
+		return location[1]
+	})
+}
+
+// ReadBytes reads exactly n bytes.
+func (s *OutputStream) ReadBytes(n int, timeout time.Duration) ([]byte, error) {
+	return s.read(fmt.Sprintf("%d bytes", n), timeout, func(unread []byte) int {
+		if len(unread) < n {
+			return -1
+		}
+
+		return n
+	})
+}
+
+// read waits until findEnd returns the length of the unread output to consume, or -1 if more output is needed.
+func (s *OutputStream) read(description string, timeout time.Duration, findEnd func(unread []byte) int) ([]byte, error) {
+	return s.waitForMatch(description, &s.readOffset, timeout, findEnd)
+}
+
+// waitForMatch is like read, but reads from *offset instead of readOffset. This lets callers (like a stdin script)
+// follow the output without consuming it. offset must only be accessed with the mutex held.
+func (s *OutputStream) waitForMatch(description string, offset *int, timeout time.Duration, findEnd func(unread []byte) int) ([]byte, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		s.mutex.Lock()
+		unread := s.data[*offset:]
+
+		if end := findEnd(unread); end != -1 {
+			result := append([]byte{}, unread[:end]...)
+			*offset += end
+			s.mutex.Unlock()
+
+			return result, nil
+		}
+
+		isClosed := s.isClosed
+		changedChan := s.changedChan
+		s.mutex.Unlock()
+
+		if isClosed {
+			return nil, &outputStreamError{
+				message: fmt.Sprintf("%s was closed before receiving %s, received: %q", s.name, description, unread),
+				cause:   io.EOF,
+			}
+		}
+
+		select {
+		case <-changedChan:
+		case <-deadline.C:
+			s.mutex.Lock()
+			unread = s.data[*offset:]
+			s.mutex.Unlock()
+
+			return nil, &outputStreamError{
+				message: fmt.Sprintf("timed out after %s waiting for %s on %s, received: %q", timeout, description, s.name, unread),
+				cause:   os.ErrDeadlineExceeded,
+			}
+		}
+	}
+}
+
+// outputStreamError is used so that callers can check the cause using errors.Is, without it being in the message.
+type outputStreamError struct {
+	message string
+	cause   error
+}
+
+func (e *outputStreamError) Error() string {
+	return e.message
+}
+
+func (e *outputStreamError) Unwrap() error {
+	return e.cause
+}
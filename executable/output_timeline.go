@@ -0,0 +1,63 @@
+package executable
+
+import (
+	"sync"
+	"time"
+)
+
+// OutputChunk is a piece of output the program wrote, as received by the tester.
+type OutputChunk struct {
+	// Stream is the stream the chunk was written to. Either "stdout" or "stderr"
+	Stream string
+
+	Data []byte
+
+	// Timestamp is the time the chunk was received, relative to when the program was started.
+	Timestamp time.Duration
+}
+
+// outputTimeline records the output a program wrote on stdout & stderr as a single ordered list of chunks.
+type outputTimeline struct {
+	startTime time.Time
+
+	mutex  sync.Mutex
+	chunks []OutputChunk
+}
+
+func newOutputTimeline(startTime time.Time) *outputTimeline {
+	return &outputTimeline{startTime: startTime}
+}
+
+// writer returns an io.Writer that records everything written to it as chunks of streamName.
+func (t *outputTimeline) writer(streamName string) *outputTimelineWriter {
+	return &outputTimelineWriter{timeline: t, streamName: streamName}
+}
+
+func (t *outputTimeline) add(streamName string, data []byte) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// The timestamp is taken with the lock held, so that timestamps never decrease along the timeline
+	t.chunks = append(t.chunks, OutputChunk{
+		Stream:    streamName,
+		Data:      append([]byte{}, data...),
+		Timestamp: time.Since(t.startTime),
+	})
+}
+
+func (t *outputTimeline) snapshot() []OutputChunk {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return append([]OutputChunk{}, t.chunks...)
+}
+
+type outputTimelineWriter struct {
+	timeline   *outputTimeline
+	streamName string
+}
+
+func (w *outputTimelineWriter) Write(bytes []byte) (n int, err error) {
+	w.timeline.add(w.streamName, bytes)
+	return len(bytes), nil
+}
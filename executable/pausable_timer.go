@@ -0,0 +1,58 @@
+package executable
+
+import (
+	"sync"
+	"time"
+)
+
+// pausableTimer calls a function once a duration has elapsed, not counting the time it spent paused.
+type pausableTimer struct {
+	mutex     sync.Mutex
+	timer     *time.Timer
+	callback  func()
+	deadline  time.Time     // When the timer fires, only valid while running
+	remaining time.Duration // Time left when paused, only valid while paused
+	isPaused  bool
+}
+
+func newPausableTimer(duration time.Duration, callback func()) *pausableTimer {
+	return &pausableTimer{
+		timer:    time.AfterFunc(duration, callback),
+		callback: callback,
+		deadline: time.Now().Add(duration),
+	}
+}
+
+// pause stops the clock. Does nothing if the timer is already paused, or has fired.
+func (t *pausableTimer) pause() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.isPaused || !t.timer.Stop() {
+		return
+	}
+
+	t.isPaused = true
+	t.remaining = time.Until(t.deadline)
+}
+
+// resume restarts the clock with the time that was left when it was paused.
+func (t *pausableTimer) resume() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.isPaused {
+		return
+	}
+
+	t.isPaused = false
+	t.deadline = time.Now().Add(t.remaining)
+	t.timer = time.AfterFunc(t.remaining, t.callback)
+}
+
+func (t *pausableTimer) stop() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.timer.Stop()
+}
@@ -1,6 +1,13 @@
 package executable
 
-import "syscall"
+import (
+	"errors"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
 
 func killProcess(pid int) {
 	syscall.Kill(pid, syscall.SIGTERM)  // Don't know if this is required
@@ -10,3 +17,126 @@ func killProcess(pid int) {
 func createProcAttribute() *syscall.SysProcAttr {
 	return &syscall.SysProcAttr{Setpgid: true}
 }
+
+func createPTYProcAttribute() *syscall.SysProcAttr {
+	// Setsid also places the process in a new process group, so killProcess(-pid) continues to work.
+	return &syscall.SysProcAttr{Setsid: true, Setctty: true}
+}
+
+// isPTYClosedError returns true if err is what reading from a pseudo-terminal master returns once the slave is closed.
+func isPTYClosedError(err error) bool {
+	return errors.Is(err, syscall.EIO)
+}
+
+func signalProcess(pid int, signal syscall.Signal) error {
+	return syscall.Kill(pid, signal)
+}
+
+func signalProcessGroup(pgid int, signal syscall.Signal) error {
+	return syscall.Kill(-pgid, signal)
+}
+
+func pauseProcessGroup(pgid int) error {
+	if err := syscall.Kill(-pgid, syscall.SIGSTOP); err != nil {
+		return err
+	}
+
+	// Signals are delivered asynchronously. A process that is being forked when SIGSTOP is sent can receive it after a
+	// SIGCONT sent right after, which leaves it stopped for good. Waiting for every process to stop avoids that.
+	waitForProcessGroupToStop(pgid, time.Second)
+
+	return nil
+}
+
+// waitForProcessGroupToStop waits until every process in the process group is stopped (or has exited), or timeout
+// elapses.
+func waitForProcessGroupToStop(pgid int, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		stats, err := listProcessGroupStats(pgid)
+		if err != nil {
+			return
+		}
+
+		isStopped := true
+		for _, stat := range stats {
+			if stat.state != 'T' && stat.state != 'Z' && stat.state != 'X' {
+				isStopped = false
+			}
+		}
+
+		if isStopped {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func resumeProcessGroup(pgid int) error {
+	return syscall.Kill(-pgid, syscall.SIGCONT)
+}
+
+// signalName returns the name of a signal. Example: "SIGSEGV"
+func signalName(signal syscall.Signal) string {
+	if name := unix.SignalName(signal); name != "" {
+		return name
+	}
+
+	return signal.String()
+}
+
+// These are the values of si_code for SIGCHLD, see sigaction(2).
+const (
+	childExitedCode = 1 // CLD_EXITED
+	childKilledCode = 2 // CLD_KILLED
+	childDumpedCode = 3 // CLD_DUMPED
+)
+
+// sigchldFields are the fields of siginfo_t that are set for SIGCHLD (see sigaction(2)). unix.Siginfo doesn't expose
+// them, they follow si_signo, si_errno & si_code, aligned to the size of a pointer.
+type sigchldFields struct {
+	pid    int32
+	uid    uint32
+	status int32
+}
+
+const sigchldFieldsOffset = (12 + unsafe.Sizeof(uintptr(0)) - 1) / unsafe.Sizeof(uintptr(0)) * unsafe.Sizeof(uintptr(0))
+
+// watchForExit returns a channel that is closed once the process has exited, after setting status to how it exited.
+// Unlike waiting for the process, this doesn't reap it, so cmd.Wait() still works (it must only be called once the
+// channel is closed). The program's output can still be open at that point, since processes it started can hold on to
+// it.
+func watchForExit(pid int, status *exitStatus) <-chan bool {
+	exited := make(chan bool)
+
+	go func() {
+		defer close(exited)
+
+		info := unix.Siginfo{}
+
+		for {
+			err := unix.Waitid(unix.P_PID, pid, &info, unix.WEXITED|unix.WNOWAIT, nil)
+			if err == nil {
+				break
+			}
+
+			if err != unix.EINTR {
+				return
+			}
+		}
+
+		fields := (*sigchldFields)(unsafe.Add(unsafe.Pointer(&info), sigchldFieldsOffset))
+
+		switch info.Code {
+		case childExitedCode:
+			status.exitCode = int(fields.status)
+		case childKilledCode, childDumpedCode:
+			status.exitCode = -1
+			status.signal = syscall.Signal(fields.status)
+		}
+	}()
+
+	return exited
+}
@@ -1,6 +1,8 @@
 package executable
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"syscall"
 )
@@ -23,3 +25,65 @@ func killProcess(pid int) {
 func createProcAttribute() *syscall.SysProcAttr {
 	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
 }
+
+func createPTYProcAttribute() *syscall.SysProcAttr {
+	return createProcAttribute()
+}
+
+func isPTYClosedError(err error) bool {
+	return false
+}
+
+func signalProcess(pid int, signal syscall.Signal) error {
+	if signal != syscall.SIGKILL && signal != syscall.SIGTERM {
+		return fmt.Errorf("sending %s is not supported on Windows", signal)
+	}
+
+	handle, err := syscall.OpenProcess(syscall.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(handle)
+
+	return syscall.TerminateProcess(handle, 1)
+}
+
+// Windows doesn't have process groups that can be signalled, so only the process itself is signalled.
+func signalProcessGroup(pgid int, signal syscall.Signal) error {
+	return signalProcess(pgid, signal)
+}
+
+func signalName(signal syscall.Signal) string {
+	return signal.String()
+}
+
+func pauseProcessGroup(pgid int) error {
+	return errors.New("pausing programs is not supported on Windows")
+}
+
+func resumeProcessGroup(pgid int) error {
+	return errors.New("resuming programs is not supported on Windows")
+}
+
+func watchForExit(pid int, status *exitStatus) <-chan bool {
+	exited := make(chan bool)
+
+	go func() {
+		defer close(exited)
+
+		handle, err := syscall.OpenProcess(syscall.SYNCHRONIZE, false, uint32(pid))
+		if err != nil {
+			return
+		}
+		defer syscall.CloseHandle(handle)
+
+		syscall.WaitForSingleObject(handle, syscall.INFINITE)
+
+		exitCode := uint32(0)
+		if err := syscall.GetExitCodeProcess(handle, &exitCode); err == nil {
+			status.exitCode = int(exitCode)
+		}
+	}()
+
+	return exited
+}
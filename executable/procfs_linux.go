@@ -0,0 +1,198 @@
+package executable
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// procStat holds the fields we use from /proc/<pid>/stat. See proc(5) for details.
+type procStat struct {
+	pid        int
+	state      byte
+	ppid       int
+	pgid       int
+	utimeTicks uint64
+	stimeTicks uint64
+	numThreads int
+	rssPages   int64
+
+	// startTimeTicks is when the process started, in clock ticks since boot. Used to tell apart processes that reuse a pid.
+	startTimeTicks uint64
+}
+
+func readProcStat(pid int) (procStat, error) {
+	contents, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return procStat{}, err
+	}
+
+	// The command name (2nd field) is wrapped in parentheses and can contain spaces, so we parse from the last ')'
+	closingParenIndex := strings.LastIndexByte(string(contents), ')')
+	if closingParenIndex == -1 {
+		return procStat{}, fmt.Errorf("unexpected format in /proc/%d/stat", pid)
+	}
+
+	// fields[0] is the 3rd field (state)
+	fields := strings.Fields(string(contents[closingParenIndex+1:]))
+	if len(fields) < 22 {
+		return procStat{}, fmt.Errorf("unexpected format in /proc/%d/stat", pid)
+	}
+
+	stat := procStat{pid: pid, state: fields[0][0]}
+	stat.ppid, _ = strconv.Atoi(fields[1])
+	stat.pgid, _ = strconv.Atoi(fields[2])
+	stat.utimeTicks, _ = strconv.ParseUint(fields[11], 10, 64)
+	stat.stimeTicks, _ = strconv.ParseUint(fields[12], 10, 64)
+	stat.numThreads, _ = strconv.Atoi(fields[17])
+	stat.startTimeTicks, _ = strconv.ParseUint(fields[19], 10, 64)
+	stat.rssPages, _ = strconv.ParseInt(fields[21], 10, 64)
+
+	return stat, nil
+}
+
+// listProcStats returns the stats of all processes visible in /proc. Processes that exit while we're reading are skipped.
+func listProcStats() ([]procStat, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	stats := []procStat{}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		stat, err := readProcStat(pid)
+		if err != nil {
+			continue
+		}
+
+		stats = append(stats, stat)
+	}
+
+	return stats, nil
+}
+
+// listProcessGroupStats returns the stats of all processes in the process group pgid.
+func listProcessGroupStats(pgid int) ([]procStat, error) {
+	stats, err := listProcStats()
+	if err != nil {
+		return nil, err
+	}
+
+	groupStats := []procStat{}
+
+	for _, stat := range stats {
+		if stat.pgid == pgid {
+			groupStats = append(groupStats, stat)
+		}
+	}
+
+	return groupStats, nil
+}
+
+// countOpenFileDescriptors returns the number of open file descriptors of a process.
+func countOpenFileDescriptors(pid int) (int, error) {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(entries), nil
+}
+
+// readCommandLine returns the command line of a process, with arguments separated by spaces. Example: "sleep 60"
+//
+// Returns an empty string for processes that are exiting, zombies & kernel threads.
+func readCommandLine(pid int) string {
+	contents, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.ReplaceAll(string(contents), "\x00", " "))
+}
+
+// listListeningAddresses returns the addresses of TCP sockets that a process is listening on. Example: "127.0.0.1:6379"
+//
+// /proc/<pid>/net is used instead of /proc/net, so that sockets in a different network namespace are found too.
+func listListeningAddresses(pid int) []string {
+	socketInodes := map[string]bool{}
+
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return []string{}
+	}
+
+	for _, entry := range entries {
+		target, err := os.Readlink(fmt.Sprintf("/proc/%d/fd/%s", pid, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		// Example: socket:[12345]
+		if inode, ok := strings.CutPrefix(target, "socket:["); ok {
+			socketInodes[strings.TrimSuffix(inode, "]")] = true
+		}
+	}
+
+	addresses := []string{}
+
+	for _, fileName := range []string{"tcp", "tcp6"} {
+		contents, err := os.ReadFile(fmt.Sprintf("/proc/%d/net/%s", pid, fileName))
+		if err != nil {
+			continue
+		}
+
+		// Example: "   0: 0100007F:18EB 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 12345 ..."
+		for _, line := range strings.Split(string(contents), "\n")[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 10 {
+				continue
+			}
+
+			const tcpListenState = "0A"
+			if fields[3] != tcpListenState || !socketInodes[fields[9]] {
+				continue
+			}
+
+			if address, err := parseProcNetAddress(fields[1]); err == nil {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+
+	return addresses
+}
+
+// parseProcNetAddress parses an address from /proc/net/tcp or /proc/net/tcp6. Example: "0100007F:18EB" -> "127.0.0.1:6379"
+func parseProcNetAddress(hexAddress string) (string, error) {
+	hexIP, hexPort, found := strings.Cut(hexAddress, ":")
+	if !found {
+		return "", fmt.Errorf("unexpected address format: %s", hexAddress)
+	}
+
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return "", err
+	}
+
+	ipBytes, err := hex.DecodeString(hexIP)
+	if err != nil || (len(ipBytes) != net.IPv4len && len(ipBytes) != net.IPv6len) {
+		return "", fmt.Errorf("unexpected address format: %s", hexAddress)
+	}
+
+	// The IP is stored as a sequence of 32-bit words in host byte order (little-endian on the platforms we support)
+	for i := 0; i < len(ipBytes); i += 4 {
+		ipBytes[i], ipBytes[i+1], ipBytes[i+2], ipBytes[i+3] = ipBytes[i+3], ipBytes[i+2], ipBytes[i+1], ipBytes[i]
+	}
+
+	return net.JoinHostPort(net.IP(ipBytes).String(), strconv.FormatUint(port, 10)), nil
+}
@@ -0,0 +1,226 @@
+package executable
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const readinessPollInterval = 50 * time.Millisecond
+
+// exitedProgramOutputGracePeriod is how long the rest of a program's output is waited for once it has exited, see
+// waitForOutputAfterExit.
+const exitedProgramOutputGracePeriod = 100 * time.Millisecond
+
+// StartAndWaitForTCPPort starts the program and blocks until address (host:port) accepts TCP connections. If that
+// doesn't happen within timeout, the program is killed and an error is returned.
+//
+// Useful for server-style programs, like a Redis or HTTP server:
+//
+//	if err := harness.Executable.StartAndWaitForTCPPort("localhost:6379", 5*time.Second); err != nil {
+//	    return err
+//	}
+//	harness.RegisterTeardownFunc(func() { harness.Executable.Kill() })
+func (e *Executable) StartAndWaitForTCPPort(address string, timeout time.Duration, args ...string) error {
+	if err := e.Start(args...); err != nil {
+		return err
+	}
+
+	if err := e.WaitForTCPPort(address, timeout); err != nil {
+		e.Kill()
+		return err
+	}
+
+	return nil
+}
+
+// WaitForTCPPort blocks until address (host:port) accepts TCP connections. An error that includes the program's output
+// is returned if the program exits or timeout elapses first.
+func (e *Executable) WaitForTCPPort(address string, timeout time.Duration) error {
+	process := e.currentProcess()
+	if process == nil {
+		return fmt.Errorf("program is not running")
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		conn, err := net.DialTimeout("tcp", address, readinessPollInterval)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+
+		select {
+		case <-process.exited:
+			e.waitForOutputAfterExit(process)
+
+			return fmt.Errorf("program exited (%s) before accepting connections on %s%s", describeExit(process.exitStatus), address, formatOutputForError(process.stdoutStream.Bytes(), process.stderrStream.Bytes()))
+		default:
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for program to accept connections on %s (last error: %s)%s", timeout, address, err, formatOutputForError(process.stdoutStream.Bytes(), process.stderrStream.Bytes()))
+		}
+
+		time.Sleep(readinessPollInterval)
+	}
+}
+
+// StartAndWaitForOutputLine starts the program and blocks until it prints a line matching pattern on stdout or stderr.
+// If that doesn't happen within timeout, the program is killed and an error is returned.
+//
+// Useful for programs that log when they're ready:
+//
+//	if _, err := harness.Executable.StartAndWaitForOutputLine(regexp.MustCompile("Listening on"), 5*time.Second); err != nil {
+//	    return err
+//	}
+func (e *Executable) StartAndWaitForOutputLine(pattern *regexp.Regexp, timeout time.Duration, args ...string) (string, error) {
+	if err := e.Start(args...); err != nil {
+		return "", err
+	}
+
+	line, err := e.WaitForOutputLine(pattern, timeout)
+	if err != nil {
+		e.Kill()
+		return "", err
+	}
+
+	return line, nil
+}
+
+// WaitForOutputLine blocks until the program has printed a line matching pattern on stdout or stderr, and returns the
+// line. Lines printed before this was called are included. An error listing the lines that were printed is returned if
+// the program exits or timeout elapses first.
+//
+// Output that doesn't end with a newline is considered a line once the program stops writing for 500ms, or exits.
+func (e *Executable) WaitForOutputLine(pattern *regexp.Regexp, timeout time.Duration) (string, error) {
+	process := e.currentProcess()
+	if process == nil {
+		return "", fmt.Errorf("program is not running")
+	}
+
+	outputLines := process.outputLines
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	checkedLinesCount := 0
+
+	findMatchingLine := func(lines []outputLine) (string, bool) {
+		for _, line := range lines[checkedLinesCount:] {
+			if pattern.MatchString(line.text) {
+				return line.text, true
+			}
+		}
+
+		checkedLinesCount = len(lines)
+
+		return "", false
+	}
+
+	for {
+		lines, changedChan := outputLines.snapshot()
+
+		if line, ok := findMatchingLine(lines); ok {
+			return line, nil
+		}
+
+		select {
+		case <-changedChan:
+		case <-process.exited:
+			// Lines printed right before exiting (and output without a trailing newline) only show up once the rest of the
+			// output is read
+			e.waitForOutputAfterExit(process)
+			lines, _ = outputLines.snapshot()
+
+			if line, ok := findMatchingLine(lines); ok {
+				return line, nil
+			}
+
+			return "", fmt.Errorf("program exited (%s) before printing a line matching %q%s", describeExit(process.exitStatus), pattern.String(), formatLinesForError(lines))
+		case <-deadline.C:
+			lines, _ = outputLines.snapshot()
+
+			return "", fmt.Errorf("timed out after %s waiting for program to print a line matching %q%s", timeout, pattern.String(), formatLinesForError(lines))
+		}
+	}
+}
+
+// waitForOutputAfterExit gives the IO relays of a program that has exited a moment to read the rest of its output.
+// Processes it started can hold on to the output, so this doesn't wait until the output is closed.
+func (e *Executable) waitForOutputAfterExit(process *runningProcess) {
+	e.startWaitingForProcess(process)
+
+	timer := time.NewTimer(exitedProgramOutputGracePeriod)
+	defer timer.Stop()
+
+	select {
+	case <-process.waitDone:
+	case <-timer.C:
+	}
+}
+
+// formatLinesForError formats the lines a program printed to be appended to an error message. Only the last few lines
+// are included.
+func formatLinesForError(lines []outputLine) string {
+	const maxLines = 50
+
+	if len(lines) == 0 {
+		return "\nProgram didn't print any output."
+	}
+
+	formatted := "\nLines received:"
+
+	if len(lines) > maxLines {
+		formatted += fmt.Sprintf("\n... (%d lines omitted)", len(lines)-maxLines)
+		lines = lines[len(lines)-maxLines:]
+	}
+
+	for _, line := range lines {
+		formatted += fmt.Sprintf("\n[%s] %s", line.streamName, line.text)
+	}
+
+	return formatted
+}
+
+// describeExit returns a short description of how the program exited. Example: "exit code 1"
+func describeExit(status exitStatus) string {
+	if status.signal != 0 {
+		return fmt.Sprintf("terminated by %s", signalName(status.signal))
+	}
+
+	return fmt.Sprintf("exit code %d", status.exitCode)
+}
+
+// formatOutputForError formats a program's output to be appended to an error message. Only the end of long output is
+// included, since that's usually where the relevant error is.
+func formatOutputForError(stdout []byte, stderr []byte) string {
+	const maxLength = 2000
+
+	formatted := ""
+
+	for _, stream := range []struct {
+		name   string
+		output []byte
+	}{{"stdout", stdout}, {"stderr", stderr}} {
+		if len(stream.output) == 0 {
+			continue
+		}
+
+		output := string(stream.output)
+		if len(output) > maxLength {
+			output = "..." + output[len(output)-maxLength:]
+		}
+
+		formatted += fmt.Sprintf("\nProgram's %s:\n%s", stream.name, strings.TrimRight(output, "\n"))
+	}
+
+	if formatted == "" {
+		return "\nProgram didn't print any output."
+	}
+
+	return formatted
+}
@@ -0,0 +1,55 @@
+package executable
+
+import "fmt"
+
+// ResourceLimits restricts the resources a program can use. A zero value means that the resource isn't limited.
+type ResourceLimits struct {
+	// MaxMemoryInBytes is the maximum resident memory (RSS) that the program's process group can use. The process group
+	// is killed if this is exceeded.
+	MaxMemoryInBytes int64
+
+	// MaxCPUTimeInSeconds is the maximum CPU time (user + system) that the program can use. Enforced by the kernel, the
+	// program receives SIGXCPU if this is exceeded.
+	MaxCPUTimeInSeconds int
+
+	// MaxOpenFiles is the maximum number of file descriptors that each process can have open. Enforced by the kernel,
+	// calls that'd exceed this fail with EMFILE.
+	MaxOpenFiles int
+
+	// MaxProcesses is the maximum number of processes in the program's process group. The process group is killed if
+	// this is exceeded.
+	MaxProcesses int
+}
+
+// These are the values ExecutableResult.ExceededResourceLimit can have.
+const (
+	ResourceLimitMemory    = "memory"
+	ResourceLimitCPUTime   = "CPU time"
+	ResourceLimitOpenFiles = "open files"
+	ResourceLimitProcesses = "processes"
+)
+
+func (l ResourceLimits) isEmpty() bool {
+	return l == ResourceLimits{}
+}
+
+// hasKernelEnforcedLimits returns true if any of the limits must be applied before the program is executed, see
+// applyResourceLimits. The other limits are enforced by polling.
+func (l ResourceLimits) hasKernelEnforcedLimits() bool {
+	return l.MaxCPUTimeInSeconds > 0 || l.MaxOpenFiles > 0
+}
+
+// exceededLimitError returns the error Wait should return when exceededLimit was hit, or nil if hitting the limit
+// doesn't terminate the program.
+func (l ResourceLimits) exceededLimitError(exceededLimit string) error {
+	switch exceededLimit {
+	case ResourceLimitMemory:
+		return fmt.Errorf("program exceeded memory limit of %d MB", l.MaxMemoryInBytes/(1024*1024))
+	case ResourceLimitCPUTime:
+		return fmt.Errorf("program exceeded CPU time limit of %d seconds", l.MaxCPUTimeInSeconds)
+	case ResourceLimitProcesses:
+		return fmt.Errorf("program exceeded limit of %d processes", l.MaxProcesses)
+	default:
+		return nil
+	}
+}
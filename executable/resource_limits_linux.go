@@ -0,0 +1,148 @@
+package executable
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const resourceLimitWatcherInterval = 20 * time.Millisecond
+
+// applyResourceLimits applies the limits that the kernel can enforce on its own to the current process. It is called in
+// the re-executed tester binary right before the program is executed (see runChildInit), so the program (and any
+// process it forks) runs with the limits from the start.
+//
+// Memory isn't limited here. RLIMIT_AS limits address space (not memory use), and runtimes like Go, Node & the JVM
+// reserve far more address space than they use, so they'd crash on startup. MaxMemoryInBytes is enforced by polling.
+func applyResourceLimits(limits ResourceLimits) error {
+	if limits.MaxCPUTimeInSeconds > 0 {
+		// The kernel sends SIGXCPU at the soft limit, and SIGKILL at the hard limit (if SIGXCPU was handled)
+		cpuLimit := uint64(limits.MaxCPUTimeInSeconds)
+		if err := lowerResourceLimit(unix.RLIMIT_CPU, cpuLimit, cpuLimit+1); err != nil {
+			return fmt.Errorf("failed to limit CPU time: %w", err)
+		}
+	}
+
+	if limits.MaxOpenFiles > 0 {
+		fileLimit := uint64(limits.MaxOpenFiles)
+		if err := lowerResourceLimit(unix.RLIMIT_NOFILE, fileLimit, fileLimit); err != nil {
+			return fmt.Errorf("failed to limit open files: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// lowerResourceLimit sets a limit for the current process. Limits that are already lower are kept, since raising a hard
+// limit isn't allowed.
+func lowerResourceLimit(resource int, soft uint64, hard uint64) error {
+	current := unix.Rlimit{}
+	if err := unix.Getrlimit(resource, &current); err != nil {
+		return err
+	}
+
+	return unix.Setrlimit(resource, &unix.Rlimit{Cur: min(soft, current.Max), Max: min(hard, current.Max)})
+}
+
+// resourceLimitWatcher polls /proc to enforce the limits that the kernel can't enforce per process group, and to detect
+// which limits were hit.
+type resourceLimitWatcher struct {
+	pgid          int
+	limits        ResourceLimits
+	stopChan      chan bool
+	doneChan      chan bool
+	exceededLimit string
+}
+
+func startResourceLimitWatcher(pgid int, limits ResourceLimits) *resourceLimitWatcher {
+	w := &resourceLimitWatcher{
+		pgid:     pgid,
+		limits:   limits,
+		stopChan: make(chan bool),
+		doneChan: make(chan bool),
+	}
+
+	go func() {
+		defer close(w.doneChan)
+
+		ticker := time.NewTicker(resourceLimitWatcherInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stopChan:
+				return
+			case <-ticker.C:
+				if w.check() {
+					return
+				}
+			}
+		}
+	}()
+
+	return w
+}
+
+// check returns true if the process group was killed for exceeding a limit.
+func (w *resourceLimitWatcher) check() bool {
+	stats, err := listProcessGroupStats(w.pgid)
+	if err != nil {
+		return false
+	}
+
+	if w.limits.MaxProcesses > 0 && len(stats) > w.limits.MaxProcesses {
+		w.exceededLimit = ResourceLimitProcesses
+		syscall.Kill(-w.pgid, syscall.SIGKILL)
+		return true
+	}
+
+	if w.limits.MaxMemoryInBytes > 0 {
+		totalMemoryInBytes := int64(0)
+		for _, stat := range stats {
+			totalMemoryInBytes += stat.rssPages * int64(os.Getpagesize())
+		}
+
+		if totalMemoryInBytes > w.limits.MaxMemoryInBytes {
+			w.exceededLimit = ResourceLimitMemory
+			syscall.Kill(-w.pgid, syscall.SIGKILL)
+			return true
+		}
+	}
+
+	if w.limits.MaxOpenFiles > 0 && w.exceededLimit == "" {
+		for _, stat := range stats {
+			if count, err := countOpenFileDescriptors(stat.pid); err == nil && count >= w.limits.MaxOpenFiles {
+				// The kernel doesn't let the process go over the limit, so reaching it is as close as we can get
+				w.exceededLimit = ResourceLimitOpenFiles
+			}
+		}
+	}
+
+	return false
+}
+
+// stop stops the watcher and returns the limit that the program ran into, if any. processState must be from the
+// process that was being watched.
+func (w *resourceLimitWatcher) stop(processState *os.ProcessState) string {
+	close(w.stopChan)
+	<-w.doneChan
+
+	if w.exceededLimit == ResourceLimitMemory || w.exceededLimit == ResourceLimitProcesses {
+		return w.exceededLimit
+	}
+
+	if w.limits.MaxCPUTimeInSeconds > 0 && processState != nil {
+		if waitStatus, ok := processState.Sys().(syscall.WaitStatus); ok && waitStatus.Signaled() {
+			cpuTime := processState.UserTime() + processState.SystemTime()
+
+			if waitStatus.Signal() == syscall.SIGXCPU || (waitStatus.Signal() == syscall.SIGKILL && cpuTime >= time.Duration(w.limits.MaxCPUTimeInSeconds)*time.Second) {
+				return ResourceLimitCPUTime
+			}
+		}
+	}
+
+	return w.exceededLimit
+}
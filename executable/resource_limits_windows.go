@@ -0,0 +1,15 @@
+package executable
+
+import (
+	"os"
+)
+
+type resourceLimitWatcher struct{}
+
+func startResourceLimitWatcher(pgid int, limits ResourceLimits) *resourceLimitWatcher {
+	return &resourceLimitWatcher{}
+}
+
+func (w *resourceLimitWatcher) stop(processState *os.ProcessState) string {
+	return ""
+}
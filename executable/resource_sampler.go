@@ -0,0 +1,60 @@
+package executable
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ResourceSample is a measurement of the resources used by the program (and any processes it started in its process
+// group) at a point in time.
+type ResourceSample struct {
+	// Timestamp is when the sample was taken, relative to when the program was started.
+	Timestamp time.Duration
+
+	Processes           int
+	Threads             int
+	OpenFileDescriptors int
+	MemoryInBytes       int64         // Resident set size
+	CPUTime             time.Duration // User + system time used so far, by processes that are still running
+}
+
+// String returns a one-line summary of the sample, useful for logging.
+func (s ResourceSample) String() string {
+	return fmt.Sprintf(
+		"+%s: processes: %d, threads: %d, open fds: %d, memory: %.1f MB, cpu time: %s",
+		s.Timestamp.Round(time.Millisecond),
+		s.Processes,
+		s.Threads,
+		s.OpenFileDescriptors,
+		float64(s.MemoryInBytes)/(1024*1024),
+		s.CPUTime.Round(time.Millisecond),
+	)
+}
+
+// ResourceSamples returns the samples recorded so far while the program is running. Returns nil if the program isn't
+// running, or if ResourceSamplingInterval isn't set.
+func (e *Executable) ResourceSamples() []ResourceSample {
+	process := e.currentProcess()
+	if process == nil || process.resourceSampler == nil {
+		return nil
+	}
+
+	return process.resourceSampler.snapshot()
+}
+
+// SampleResources measures the resources the running program is using right now. It works even if
+// ResourceSamplingInterval isn't set. Useful for checking that a resource doesn't grow:
+//
+//	before, _ := harness.Executable.SampleResources()
+//	// ... open & close 1000 connections ...
+//	after, _ := harness.Executable.SampleResources()
+//	if after.OpenFileDescriptors > before.OpenFileDescriptors { ... }
+func (e *Executable) SampleResources() (ResourceSample, error) {
+	process := e.currentProcess()
+	if process == nil {
+		return ResourceSample{}, errors.New("program is not running")
+	}
+
+	return sampleProcessGroup(process.cmd.Process.Pid, process.startTime)
+}
@@ -0,0 +1,110 @@
+package executable
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// clockTicksPerSecond is the unit of CPU times in /proc/<pid>/stat. It is 100 on all the platforms Linux exposes to
+// userspace (USER_HZ), regardless of the kernel's internal tick rate.
+const clockTicksPerSecond = 100
+
+// resourceSampler periodically records a ResourceSample of a process group.
+type resourceSampler struct {
+	pgid      int
+	startTime time.Time
+
+	mutex   sync.Mutex
+	samples []ResourceSample
+
+	stopChan chan bool
+	doneChan chan bool
+}
+
+func startResourceSampler(pgid int, startTime time.Time, interval time.Duration) *resourceSampler {
+	s := &resourceSampler{
+		pgid:      pgid,
+		startTime: startTime,
+		samples:   []ResourceSample{},
+		stopChan:  make(chan bool),
+		doneChan:  make(chan bool),
+	}
+
+	go func() {
+		defer close(s.doneChan)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.recordSample()
+
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return s
+}
+
+func (s *resourceSampler) recordSample() {
+	sample, err := sampleProcessGroup(s.pgid, s.startTime)
+	if err != nil {
+		return // The program has exited
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.samples = append(s.samples, sample)
+}
+
+func (s *resourceSampler) snapshot() []ResourceSample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]ResourceSample{}, s.samples...)
+}
+
+// stop stops the sampler and returns all samples recorded.
+func (s *resourceSampler) stop() []ResourceSample {
+	close(s.stopChan)
+	<-s.doneChan
+
+	return s.snapshot()
+}
+
+func sampleProcessGroup(pgid int, startTime time.Time) (ResourceSample, error) {
+	stats, err := listProcessGroupStats(pgid)
+	if err != nil {
+		return ResourceSample{}, err
+	}
+
+	sample := ResourceSample{Timestamp: time.Since(startTime)}
+
+	for _, stat := range stats {
+		if stat.state == 'Z' {
+			continue
+		}
+
+		sample.Processes++
+		sample.Threads += stat.numThreads
+		sample.MemoryInBytes += stat.rssPages * int64(os.Getpagesize())
+		sample.CPUTime += time.Duration(stat.utimeTicks+stat.stimeTicks) * time.Second / clockTicksPerSecond
+
+		if count, err := countOpenFileDescriptors(stat.pid); err == nil {
+			sample.OpenFileDescriptors += count
+		}
+	}
+
+	if sample.Processes == 0 {
+		return ResourceSample{}, fmt.Errorf("no running processes in process group %d", pgid)
+	}
+
+	return sample, nil
+}
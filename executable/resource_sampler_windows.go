@@ -0,0 +1,24 @@
+package executable
+
+import (
+	"errors"
+	"time"
+)
+
+type resourceSampler struct{}
+
+func startResourceSampler(pgid int, startTime time.Time, interval time.Duration) *resourceSampler {
+	return &resourceSampler{}
+}
+
+func (s *resourceSampler) snapshot() []ResourceSample {
+	return []ResourceSample{}
+}
+
+func (s *resourceSampler) stop() []ResourceSample {
+	return []ResourceSample{}
+}
+
+func sampleProcessGroup(pgid int, startTime time.Time) (ResourceSample, error) {
+	return ResourceSample{}, errors.New("resource sampling is not supported on Windows")
+}
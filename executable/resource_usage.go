@@ -0,0 +1,37 @@
+package executable
+
+import (
+	"fmt"
+	"time"
+)
+
+// ResourceUsage holds the resources a program used while it ran.
+type ResourceUsage struct {
+	// WallTime is the time between the program being started and its exit being observed.
+	WallTime time.Duration
+
+	// UserCPUTime and SystemCPUTime are the CPU time spent in user & kernel mode respectively.
+	UserCPUTime   time.Duration
+	SystemCPUTime time.Duration
+
+	// PeakMemoryInBytes is the maximum resident set size (RSS) of the program. Always 0 on Windows.
+	PeakMemoryInBytes int64
+
+	// VoluntaryContextSwitches and InvoluntaryContextSwitches are the number of times the program gave up the CPU
+	// (usually to wait for I/O) and was preempted respectively. Always 0 on Windows.
+	VoluntaryContextSwitches   int64
+	InvoluntaryContextSwitches int64
+}
+
+// String returns a one-line summary, useful for debug logs.
+func (u ResourceUsage) String() string {
+	return fmt.Sprintf(
+		"wall time: %s, cpu time: %s user / %s system, peak memory: %.1f MB, context switches: %d voluntary / %d involuntary",
+		u.WallTime.Round(time.Millisecond),
+		u.UserCPUTime.Round(time.Millisecond),
+		u.SystemCPUTime.Round(time.Millisecond),
+		float64(u.PeakMemoryInBytes)/(1024*1024),
+		u.VoluntaryContextSwitches,
+		u.InvoluntaryContextSwitches,
+	)
+}
@@ -0,0 +1,17 @@
+package executable
+
+import (
+	"os"
+	"syscall"
+)
+
+func readResourceUsage(processState *os.ProcessState, usage *ResourceUsage) {
+	usage.UserCPUTime = processState.UserTime()
+	usage.SystemCPUTime = processState.SystemTime()
+
+	if rusage, ok := processState.SysUsage().(*syscall.Rusage); ok {
+		usage.PeakMemoryInBytes = rusage.Maxrss * 1024 // ru_maxrss is in kilobytes
+		usage.VoluntaryContextSwitches = rusage.Nvcsw
+		usage.InvoluntaryContextSwitches = rusage.Nivcsw
+	}
+}
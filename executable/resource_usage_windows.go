@@ -0,0 +1,8 @@
+package executable
+
+import "os"
+
+func readResourceUsage(processState *os.ProcessState, usage *ResourceUsage) {
+	usage.UserCPUTime = processState.UserTime()
+	usage.SystemCPUTime = processState.SystemTime()
+}
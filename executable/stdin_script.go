@@ -0,0 +1,166 @@
+package executable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"syscall"
+	"time"
+)
+
+// StdinScript describes input to send to a program over time, like a user typing. Build one using NewStdinScript:
+//
+//	script := executable.NewStdinScript().
+//	    WaitForStdout(regexp.MustCompile(`> $`), time.Second).
+//	    WriteString("SET foo ").
+//	    Sleep(100 * time.Millisecond). // Partial line
+//	    WriteString("bar\n").
+//	    WaitForStdout(regexp.MustCompile(`OK\n`), time.Second).
+//	    KeepStdinOpen()
+//
+// By default stdin is closed once all steps are done.
+type StdinScript struct {
+	steps               []stdinScriptStep
+	shouldKeepStdinOpen bool
+}
+
+type stdinScriptStep struct {
+	chunk []byte
+
+	delay time.Duration
+
+	// These are set for steps that wait for output
+	streamName string
+	pattern    *regexp.Regexp
+	timeout    time.Duration
+}
+
+func NewStdinScript() *StdinScript {
+	return &StdinScript{}
+}
+
+// Write adds a step that writes chunk to stdin.
+func (s *StdinScript) Write(chunk []byte) *StdinScript {
+	s.steps = append(s.steps, stdinScriptStep{chunk: chunk})
+	return s
+}
+
+// WriteString adds a step that writes chunk to stdin.
+func (s *StdinScript) WriteString(chunk string) *StdinScript {
+	return s.Write([]byte(chunk))
+}
+
+// Sleep adds a step that waits for delay before moving on to the next step.
+func (s *StdinScript) Sleep(delay time.Duration) *StdinScript {
+	s.steps = append(s.steps, stdinScriptStep{delay: delay})
+	return s
+}
+
+// WaitForStdout adds a step that waits until stdout matches pattern. Only output printed after the previous
+// WaitForStdout step matched is considered. The script fails if this doesn't happen within timeout.
+func (s *StdinScript) WaitForStdout(pattern *regexp.Regexp, timeout time.Duration) *StdinScript {
+	s.steps = append(s.steps, stdinScriptStep{streamName: "stdout", pattern: pattern, timeout: timeout})
+	return s
+}
+
+// WaitForStderr is like WaitForStdout, but for stderr.
+func (s *StdinScript) WaitForStderr(pattern *regexp.Regexp, timeout time.Duration) *StdinScript {
+	s.steps = append(s.steps, stdinScriptStep{streamName: "stderr", pattern: pattern, timeout: timeout})
+	return s
+}
+
+// CloseStdin makes the script close stdin (sending EOF) once all steps are done. This is the default.
+func (s *StdinScript) CloseStdin() *StdinScript {
+	s.shouldKeepStdinOpen = false
+	return s
+}
+
+// KeepStdinOpen makes the script leave stdin open once all steps are done, until the program exits. Useful for testing
+// that a program acts without waiting for EOF.
+func (s *StdinScript) KeepStdinOpen() *StdinScript {
+	s.shouldKeepStdinOpen = true
+	return s
+}
+
+// StartWithStdinScript starts the program, and runs script in the background. Errors from the script (like timing out
+// waiting for output) are returned from Wait.
+func (e *Executable) StartWithStdinScript(script *StdinScript, args ...string) error {
+	_, err := e.start(context.Background(), script, args)
+	return err
+}
+
+// RunWithStdinScript starts the program, runs script, waits for the program to complete and returns the result.
+func (e *Executable) RunWithStdinScript(script *StdinScript, args ...string) (ExecutableResult, error) {
+	if err := e.StartWithStdinScript(script, args...); err != nil {
+		return ExecutableResult{}, err
+	}
+
+	return e.Wait()
+}
+
+func (process *runningProcess) runStdinScript(script *StdinScript) error {
+	// Each stream is followed from where the previous step for it matched
+	offsets := map[string]*int{"stdout": new(int), "stderr": new(int)}
+	streams := map[string]*OutputStream{"stdout": process.stdoutStream, "stderr": process.stderrStream}
+
+	for _, step := range script.steps {
+		switch {
+		case step.pattern != nil:
+			description := fmt.Sprintf("output matching %q", step.pattern.String())
+			_, err := streams[step.streamName].waitForMatch(description, offsets[step.streamName], step.timeout, func(unread []byte) int {
+				location := step.pattern.FindIndex(unread)
+				if location == nil {
+					return -1
+				}
+
+				return location[1]
+			})
+
+			if err != nil {
+				return err
+			}
+		case step.delay > 0:
+			if !process.sleepUnlessDone(step.delay) {
+				return nil // The program exited (or was killed), the result will show what happened
+			}
+		default:
+			if _, err := process.stdinPipe.Write(step.chunk); err != nil {
+				if isStdinClosedError(err) {
+					return nil // The program exited (or closed stdin), the result will show what happened
+				}
+
+				return fmt.Errorf("failed to write to stdin: %w", err)
+			}
+		}
+	}
+
+	if !script.shouldKeepStdinOpen {
+		process.stdinPipe.Close()
+	}
+
+	return nil
+}
+
+// sleepUnlessDone waits for delay, and returns false if the program exits (or is killed) before that. Wait waits for the
+// script, so it must not be held up by a program that is already done.
+func (process *runningProcess) sleepUnlessDone(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-process.exited:
+		return false
+	case <-process.ctxWithTimeout.Done():
+		return false
+	}
+}
+
+// isStdinClosedError returns true if err is what writing to stdin returns once the program has stopped reading it.
+func isStdinClosedError(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrClosed) || errors.Is(err, io.ErrClosedPipe) || isPTYClosedError(err)
+}
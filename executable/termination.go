@@ -0,0 +1,81 @@
+package executable
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// exitStatus is how a process exited, see watchForExit.
+type exitStatus struct {
+	exitCode int            // -1 if the process was terminated by a signal
+	signal   syscall.Signal // 0 if the process exited by itself
+}
+
+// readTerminationStatus populates the fields in result that describe how the program terminated.
+func readTerminationStatus(processState *os.ProcessState, result *ExecutableResult) {
+	waitStatus, ok := processState.Sys().(syscall.WaitStatus)
+	if !ok || !waitStatus.Signaled() {
+		return
+	}
+
+	result.TerminationSignal = waitStatus.Signal()
+	result.TerminationSignalName = signalName(waitStatus.Signal())
+	result.CoreDumped = waitStatus.CoreDump()
+}
+
+// ExitedNormally returns true if the program exited by itself, i.e. it wasn't terminated by a signal or timed out.
+func (r ExecutableResult) ExitedNormally() bool {
+	return r.TerminationSignal == 0 && !r.TimedOut
+}
+
+// TerminationExplanation returns a user-friendly explanation of why the program terminated abnormally. Returns an
+// empty string if the program exited normally.
+func (r ExecutableResult) TerminationExplanation() string {
+	if r.TimedOut {
+		return "Program was killed because it didn't exit before the timeout."
+	}
+
+	if r.ExceededResourceLimit == ResourceLimitMemory {
+		return "Program was killed because it exceeded the memory limit."
+	}
+
+	if r.ExceededResourceLimit == ResourceLimitCPUTime {
+		return "Program was killed because it exceeded the CPU time limit. Check for infinite loops."
+	}
+
+	if r.ExceededResourceLimit == ResourceLimitProcesses {
+		return "Program was killed because it started too many processes."
+	}
+
+	if r.TerminationSignal == 0 {
+		return ""
+	}
+
+	explanation := ""
+
+	switch r.TerminationSignal {
+	case syscall.SIGSEGV:
+		explanation = "Program crashed with a segmentation fault (SIGSEGV). This usually means it accessed invalid memory, like dereferencing a null pointer or overflowing the stack."
+	case syscall.SIGBUS:
+		explanation = "Program crashed with a bus error (SIGBUS). This usually means it accessed misaligned or unmapped memory."
+	case syscall.SIGABRT:
+		explanation = "Program aborted (SIGABRT). This usually means an assertion failed or the runtime detected a fatal error, check the program's output for details."
+	case syscall.SIGFPE:
+		explanation = "Program crashed with an arithmetic error (SIGFPE). This usually means it divided an integer by zero."
+	case syscall.SIGILL:
+		explanation = "Program crashed with an illegal instruction (SIGILL). This can happen when the runtime detects a fatal error, like Rust's panic=abort or Swift's fatalError."
+	case syscall.SIGKILL:
+		explanation = "Program was killed (SIGKILL). If this wasn't done by the tester, the most common reason is running out of memory."
+	case syscall.SIGPIPE:
+		explanation = "Program was terminated by SIGPIPE. This means it wrote to a pipe or socket that was already closed."
+	default:
+		explanation = fmt.Sprintf("Program was terminated by %s.", r.TerminationSignalName)
+	}
+
+	if r.CoreDumped {
+		explanation += " A core dump was produced."
+	}
+
+	return explanation
+}
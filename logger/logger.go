@@ -1,49 +1,250 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 )
 
-func colorize(colorToUse color.Attribute, fstring string, args ...interface{}) []string {
+// OutputFormat determines how a Logger writes log lines.
+type OutputFormat string
+
+const (
+	// TextOutputFormat writes colored lines meant to be read by humans. This is the default.
+	TextOutputFormat OutputFormat = "text"
+
+	// JSONOutputFormat writes one JSON object per line, meant to be read by tools (like a web UI). Example:
+	//
+	//	{"timestamp":"2024-01-01T00:00:00.000Z","level":"info","prefix":"stage-1","secondary_prefix":"","message":"Running tests"}
+	JSONOutputFormat OutputFormat = "json"
+)
+
+// defaultOutputFormat is used by all loggers created after it is set. The tester sets this based on the tester context.
+var defaultOutputFormat = TextOutputFormat
+
+// SetDefaultOutputFormat sets the format used by loggers created after this call.
+func SetDefaultOutputFormat(format OutputFormat) {
+	defaultOutputFormat = format
+}
+
+// ParseOutputFormat parses a format name (like the value of CODECRAFTERS_LOG_FORMAT). An empty value means text.
+func ParseOutputFormat(value string) (OutputFormat, error) {
+	switch OutputFormat(value) {
+	case "", TextOutputFormat:
+		return TextOutputFormat, nil
+	case JSONOutputFormat:
+		return JSONOutputFormat, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q, expected %q or %q", value, TextOutputFormat, JSONOutputFormat)
+	}
+}
+
+// ColorPolicy determines whether a Logger adds colors to its output.
+type ColorPolicy string
+
+const (
+	// AutoColorPolicy adds colors if the logger writes to a terminal. NO_COLOR disables colors, FORCE_COLOR enables them
+	// even if the output isn't a terminal (useful when output is piped to something that renders colors). This is the
+	// default.
+	AutoColorPolicy ColorPolicy = "auto"
+
+	// AlwaysColorPolicy always adds colors. Useful for tests that compare output against fixtures.
+	AlwaysColorPolicy ColorPolicy = "always"
+
+	// NeverColorPolicy never adds colors.
+	NeverColorPolicy ColorPolicy = "never"
+)
+
+// defaultColorPolicy is used by all loggers created after it is set.
+var defaultColorPolicy = AutoColorPolicy
+
+// SetDefaultColorPolicy sets the color policy used by loggers created after this call.
+func SetDefaultColorPolicy(policy ColorPolicy) {
+	defaultColorPolicy = policy
+}
+
+// defaultWriter is what GetLogger & GetQuietLogger write to. If nil, os.Stdout is used. os.Stdout is looked up on every
+// write (instead of once), since tests replace it.
+var defaultWriter io.Writer
+
+// defaultWriterMutex guards defaultWriter, since it can be changed while other goroutines are logging.
+var defaultWriterMutex sync.Mutex
+
+// SetDefaultWriter sets the writer used by loggers created using GetLogger & GetQuietLogger, including ones created
+// before this call. Useful when embedding a tester in another program, to capture logs in a buffer or a file. Pass nil
+// to go back to os.Stdout.
+//
+// The previous writer (nil if none was set) is returned, so that it can be restored.
+func SetDefaultWriter(writer io.Writer) (previousWriter io.Writer) {
+	defaultWriterMutex.Lock()
+	defer defaultWriterMutex.Unlock()
+
+	previousWriter = defaultWriter
+	defaultWriter = writer
+
+	return previousWriter
+}
+
+// DefaultWriter returns the writer that loggers created using GetLogger & GetQuietLogger currently write to.
+func DefaultWriter() io.Writer {
+	defaultWriterMutex.Lock()
+	defer defaultWriterMutex.Unlock()
+
+	if defaultWriter == nil {
+		return os.Stdout
+	}
+
+	return defaultWriter
+}
+
+// defaultWriterProxy writes to DefaultWriter() at the time of each write.
+type defaultWriterProxy struct{}
+
+func (defaultWriterProxy) Write(bytes []byte) (int, error) {
+	return DefaultWriter().Write(bytes)
+}
+
+// shouldUseColors decides whether a logger that writes to writer should add colors, based on defaultColorPolicy.
+func shouldUseColors(writer io.Writer) bool {
+	switch defaultColorPolicy {
+	case AlwaysColorPolicy:
+		return true
+	case NeverColorPolicy:
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	if os.Getenv("FORCE_COLOR") != "" {
+		return true
+	}
+
+	file, ok := writer.(*os.File)
+	if !ok {
+		return false
+	}
+
+	return isatty.IsTerminal(file.Fd()) || isatty.IsCygwinTerminal(file.Fd())
+}
+
+// debugLogWriter receives all logs (including debug logs when IsDebug is false), without colors & with timestamps. This
+// lets users (or support) see what happened in a run without having to re-run it with debug mode turned on.
+var debugLogWriter io.Writer
+
+// debugLogMutex guards debugLogWriter, and makes sure lines from concurrent loggers don't interleave.
+var debugLogMutex sync.Mutex
+
+// SetDebugLogWriter sets where the debug log is written (by all loggers). Pass nil to stop writing it. Example line:
+//
+//	2024-01-01T00:00:00.000Z debug    [stage-1] [client-1] Sending "PING"
+func SetDebugLogWriter(writer io.Writer) {
+	debugLogMutex.Lock()
+	defer debugLogMutex.Unlock()
+
+	debugLogWriter = writer
+}
+
+// PrintBlankLine prints an empty line, used to visually separate groups of logs (like stages). Nothing is printed in
+// JSON mode, since every line must be a JSON object.
+func PrintBlankLine() {
+	if defaultOutputFormat == JSONOutputFormat {
+		return
+	}
+
+	fmt.Fprintln(DefaultWriter(), "")
+}
+
+type logLevel string
+
+const (
+	debugLevel    logLevel = "debug"
+	infoLevel     logLevel = "info"
+	successLevel  logLevel = "success"
+	errorLevel    logLevel = "error"
+	criticalLevel logLevel = "critical"
+	plainLevel    logLevel = "plain"
+)
+
+// jsonLogLine is what's written for each line in JSON mode
+type jsonLogLine struct {
+	Timestamp         string   `json:"timestamp"`
+	Level             logLevel `json:"level"`
+	Prefix            string   `json:"prefix"`
+	SecondaryPrefix   string   `json:"secondary_prefix"`   // The innermost secondary prefix, see GetSecondaryPrefix
+	SecondaryPrefixes []string `json:"secondary_prefixes"` // Outermost first. Example: ["replica-2", "handshake"]
+	Message           string   `json:"message"`
+}
+
+// lineToEmit holds a log line with & without colors, so that the logger can pick one based on its output format.
+type lineToEmit struct {
+	plain     string
+	colorized string
+}
+
+func colorize(isColorEnabled bool, colorToUse color.Attribute, fstring string, args ...interface{}) []lineToEmit {
 	msg := fmt.Sprintf(fstring, args...)
 	lines := strings.Split(msg, "\n")
-	colorizedLines := make([]string, len(lines))
+	colorizedLines := make([]lineToEmit, len(lines))
+
+	// Colors are enabled/disabled per logger, fatih/color's global NoColor is left as-is for other users of the package
+	colorizer := color.New(colorToUse)
+	if isColorEnabled {
+		colorizer.EnableColor()
+	} else {
+		colorizer.DisableColor()
+	}
 
 	for i, line := range lines {
-		colorizedLines[i] = color.New(colorToUse).SprintFunc()(line)
+		colorizedLines[i] = lineToEmit{plain: line, colorized: colorizer.SprintFunc()(line)}
 	}
 
 	return colorizedLines
 }
 
-func debugColorize(fstring string, args ...interface{}) []string {
-	return colorize(color.FgCyan, fstring, args...)
+func plainLines(msg string) []lineToEmit {
+	lines := strings.Split(msg, "\n")
+	plainLines := make([]lineToEmit, len(lines))
+
+	for i, line := range lines {
+		plainLines[i] = lineToEmit{plain: line, colorized: line}
+	}
+
+	return plainLines
 }
 
-func infoColorize(fstring string, args ...interface{}) []string {
-	return colorize(color.FgHiBlue, fstring, args...)
+func (l *Logger) debugColorize(fstring string, args ...interface{}) []lineToEmit {
+	return colorize(l.isColorEnabled, color.FgCyan, fstring, args...)
 }
 
-func successColorize(fstring string, args ...interface{}) []string {
-	return colorize(color.FgHiGreen, fstring, args...)
+func (l *Logger) infoColorize(fstring string, args ...interface{}) []lineToEmit {
+	return colorize(l.isColorEnabled, color.FgHiBlue, fstring, args...)
 }
 
-func errorColorize(fstring string, args ...interface{}) []string {
-	return colorize(color.FgHiRed, fstring, args...)
+func (l *Logger) successColorize(fstring string, args ...interface{}) []lineToEmit {
+	return colorize(l.isColorEnabled, color.FgHiGreen, fstring, args...)
 }
 
-func yellowColorize(fstring string, args ...interface{}) []string {
-	return colorize(color.FgYellow, fstring, args...)
+func (l *Logger) errorColorize(fstring string, args ...interface{}) []lineToEmit {
+	return colorize(l.isColorEnabled, color.FgHiRed, fstring, args...)
+}
+
+func (l *Logger) yellowColorize(fstring string, args ...interface{}) []lineToEmit {
+	return colorize(l.isColorEnabled, color.FgYellow, fstring, args...)
 }
 
 // Logger is a wrapper around log.Logger with the following features:
 //   - Supports a prefix
-//   - Adds colors to the output
+//   - Adds colors to the output (see ColorPolicy)
 //   - Debug mode (all logs, debug and above)
 //   - Quiet mode (only critical logs)
 type Logger struct {
@@ -56,93 +257,159 @@ type Logger struct {
 	// prefix is the prefix to be used for all logs.
 	prefix string
 
-	// secondaryPrefix is a secondary prefix, that can be dynamically appended to the prefix.
-	secondaryPrefix string
+	// secondaryPrefixes is a stack of prefixes that can be dynamically appended to the prefix, outermost first. Example:
+	// ["replica-2", "handshake"] is displayed as "[stage-3] [replica-2] [handshake] "
+	secondaryPrefixes []string
+
+	// secondaryPrefixesMutex guards secondaryPrefixes, since prefixes can be changed while other goroutines are logging.
+	secondaryPrefixesMutex sync.Mutex
+
+	// outputFormat is the value of defaultOutputFormat when the logger was created
+	outputFormat OutputFormat
+
+	// isColorEnabled is decided when the logger is created, based on defaultColorPolicy and the writer
+	isColorEnabled bool
 
 	logger log.Logger
 }
 
-// GetLogger Returns a logger.
+// GetLogger Returns a logger that writes to os.Stdout (or the writer set using SetDefaultWriter, see DefaultWriter).
 func GetLogger(isDebug bool, prefix string) *Logger {
-	color.NoColor = false
+	// Colors are decided based on the current default writer. If it's changed later (to buffer logs, for example), the
+	// logs are still meant to end up there.
+	return newLogger(defaultWriterProxy{}, shouldUseColors(DefaultWriter()), isDebug, false, prefix)
+}
 
-	coloredPrefix := yellowColorize(prefix)[0]
-	return &Logger{
-		logger:  *log.New(os.Stdout, coloredPrefix, 0),
-		IsDebug: isDebug,
-		prefix:  prefix,
+// GetLoggerWithWriter Returns a logger that writes to writer.
+func GetLoggerWithWriter(writer io.Writer, isDebug bool, prefix string) *Logger {
+	return newLogger(writer, shouldUseColors(writer), isDebug, false, prefix)
+}
+
+func newLogger(writer io.Writer, isColorEnabled bool, isDebug bool, isQuiet bool, prefix string) *Logger {
+	l := &Logger{
+		IsDebug:        isDebug,
+		IsQuiet:        isQuiet,
+		prefix:         prefix,
+		outputFormat:   defaultOutputFormat,
+		isColorEnabled: isColorEnabled,
 	}
+
+	l.logger = *log.New(writer, l.yellowColorize(prefix)[0].colorized, 0)
+
+	return l
 }
 
+// GetSecondaryPrefix returns the innermost secondary prefix, or "" if there's none.
 func (l *Logger) GetSecondaryPrefix() string {
-	return l.secondaryPrefix
+	l.secondaryPrefixesMutex.Lock()
+	defer l.secondaryPrefixesMutex.Unlock()
+
+	if len(l.secondaryPrefixes) == 0 {
+		return ""
+	}
+
+	return l.secondaryPrefixes[len(l.secondaryPrefixes)-1]
 }
 
+// UpdateSecondaryPrefix replaces all secondary prefixes with prefix. An empty prefix removes all secondary prefixes.
+//
+// Prefer PushSecondaryPrefix/PopSecondaryPrefix (or WithSecondaryPrefix), which don't discard outer prefixes.
 func (l *Logger) UpdateSecondaryPrefix(prefix string) {
-	l.secondaryPrefix = prefix
+	l.secondaryPrefixesMutex.Lock()
+	defer l.secondaryPrefixesMutex.Unlock()
+
 	if prefix == "" {
-		// Reset the prefix to the original one.
-		l.logger.SetPrefix(yellowColorize(l.prefix)[0])
+		l.secondaryPrefixes = nil
 	} else {
-		// Append the secondary prefix to the original one.
-		l.logger.SetPrefix(yellowColorize(l.prefix + fmt.Sprintf("[%s] ", prefix))[0])
+		l.secondaryPrefixes = []string{prefix}
 	}
+
+	l.updateLogPrefix()
 }
 
+// ResetSecondaryPrefix removes all secondary prefixes.
 func (l *Logger) ResetSecondaryPrefix() {
 	l.UpdateSecondaryPrefix("")
 }
 
-// GetQuietLogger Returns a logger that only emits critical logs. Useful for anti-cheat stages.
-func GetQuietLogger(prefix string) *Logger {
-	color.NoColor = false
+// PushSecondaryPrefix appends prefix to the secondary prefixes, until PopSecondaryPrefix is called:
+//
+//	logger.PushSecondaryPrefix("replica-2")
+//	defer logger.PopSecondaryPrefix()
+func (l *Logger) PushSecondaryPrefix(prefix string) {
+	l.secondaryPrefixesMutex.Lock()
+	defer l.secondaryPrefixesMutex.Unlock()
 
-	coloredPrefix := yellowColorize(prefix)[0]
-	return &Logger{
-		logger:  *log.New(os.Stdout, coloredPrefix, 0),
-		IsDebug: false,
-		IsQuiet: true,
-		prefix:  prefix,
-	}
+	l.secondaryPrefixes = append(l.secondaryPrefixes, prefix)
+	l.updateLogPrefix()
 }
 
-func (l *Logger) Successf(fstring string, args ...interface{}) {
-	if l.IsQuiet {
+// PopSecondaryPrefix removes the innermost secondary prefix. Nothing happens if there's none.
+func (l *Logger) PopSecondaryPrefix() {
+	l.secondaryPrefixesMutex.Lock()
+	defer l.secondaryPrefixesMutex.Unlock()
+
+	if len(l.secondaryPrefixes) == 0 {
 		return
 	}
 
-	for _, line := range successColorize(fstring, args...) {
-		l.logger.Println(line)
+	l.secondaryPrefixes = l.secondaryPrefixes[:len(l.secondaryPrefixes)-1]
+	l.updateLogPrefix()
+}
+
+// WithSecondaryPrefix pushes prefix while f runs, and returns f's error. The prefix is popped even if f returns early
+// or panics:
+//
+//	err := logger.WithSecondaryPrefix("handshake", func() error {
+//	    return client.Handshake()
+//	})
+func (l *Logger) WithSecondaryPrefix(prefix string, f func() error) error {
+	l.PushSecondaryPrefix(prefix)
+	defer l.PopSecondaryPrefix()
+
+	return f()
+}
+
+// formatSecondaryPrefixes returns secondary prefixes as they're displayed. Example: "[replica-2] [handshake] ". Must be
+// called with secondaryPrefixesMutex held.
+func (l *Logger) formatSecondaryPrefixes() string {
+	formatted := ""
+	for _, secondaryPrefix := range l.secondaryPrefixes {
+		formatted += fmt.Sprintf("[%s] ", secondaryPrefix)
 	}
+
+	return formatted
+}
+
+// updateLogPrefix must be called with secondaryPrefixesMutex held, after secondaryPrefixes is changed.
+func (l *Logger) updateLogPrefix() {
+	l.logger.SetPrefix(l.yellowColorize(l.prefix + l.formatSecondaryPrefixes())[0].colorized)
+}
+
+// GetQuietLogger Returns a logger that only emits critical logs. Useful for anti-cheat stages.
+func GetQuietLogger(prefix string) *Logger {
+	return newLogger(defaultWriterProxy{}, shouldUseColors(DefaultWriter()), false, true, prefix)
+}
+
+// GetQuietLoggerWithWriter is like GetQuietLogger, but writes to writer.
+func GetQuietLoggerWithWriter(writer io.Writer, prefix string) *Logger {
+	return newLogger(writer, shouldUseColors(writer), false, true, prefix)
+}
+
+func (l *Logger) Successf(fstring string, args ...interface{}) {
+	l.emit(successLevel, l.successColorize(fstring, args...))
 }
 
 func (l *Logger) Successln(msg string) {
-	if l.IsQuiet {
-		return
-	}
-	for _, line := range successColorize(msg) {
-		l.logger.Println(line)
-	}
+	l.emit(successLevel, l.successColorize(msg))
 }
 
 func (l *Logger) Infof(fstring string, args ...interface{}) {
-	if l.IsQuiet {
-		return
-	}
-
-	for _, line := range infoColorize(fstring, args...) {
-		l.logger.Println(line)
-	}
+	l.emit(infoLevel, l.infoColorize(fstring, args...))
 }
 
 func (l *Logger) Infoln(msg string) {
-	if l.IsQuiet {
-		return
-	}
-
-	for _, line := range infoColorize(msg) {
-		l.logger.Println(line)
-	}
+	l.emit(infoLevel, l.infoColorize(msg))
 }
 
 // Criticalf is to be used only in anti-cheat stages
@@ -151,9 +418,7 @@ func (l *Logger) Criticalf(fstring string, args ...interface{}) {
 		panic("Critical is only for quiet loggers")
 	}
 
-	for _, line := range errorColorize(fstring, args...) {
-		l.logger.Println(line)
-	}
+	l.emit(criticalLevel, l.errorColorize(fstring, args...))
 }
 
 // Criticalln is to be used only in anti-cheat stages
@@ -162,63 +427,115 @@ func (l *Logger) Criticalln(msg string) {
 		panic("Critical is only for quiet loggers")
 	}
 
-	for _, line := range errorColorize(msg) {
-		l.logger.Println(line)
-	}
+	l.emit(criticalLevel, l.errorColorize(msg))
 }
 
 func (l *Logger) Errorf(fstring string, args ...interface{}) {
-	if l.IsQuiet {
-		return
-	}
-
-	for _, line := range errorColorize(fstring, args...) {
-		l.logger.Println(line)
-	}
+	l.emit(errorLevel, l.errorColorize(fstring, args...))
 }
 
 func (l *Logger) Errorln(msg string) {
-	if l.IsQuiet {
-		return
+	l.emit(errorLevel, l.errorColorize(msg))
+}
+
+func (l *Logger) Debugf(fstring string, args ...interface{}) {
+	l.emit(debugLevel, l.debugColorize(fstring, args...))
+}
+
+func (l *Logger) Debugln(msg string) {
+	l.emit(debugLevel, l.debugColorize(msg))
+}
+
+func (l *Logger) Plainf(fstring string, args ...interface{}) {
+	l.emit(plainLevel, plainLines(fmt.Sprintf(fstring, args...)))
+}
+
+func (l *Logger) Plainln(msg string) {
+	l.emit(plainLevel, plainLines(msg))
+}
+
+// emit writes lines, which are already formatted (and colorized). All log methods go through this.
+func (l *Logger) emit(level logLevel, lines []lineToEmit) {
+	isVisible := l.isVisible(level)
+
+	// Anti-cheat stages (quiet loggers) aren't meant to be seen, so only what's visible is written to the debug log
+	if isVisible || !l.IsQuiet {
+		l.writeToDebugLog(level, lines)
 	}
 
-	for _, line := range errorColorize(msg) {
-		l.logger.Println(line)
+	if !isVisible {
+		return
 	}
-}
 
-func (l *Logger) Debugf(fstring string, args ...interface{}) {
-	if !l.IsDebug {
+	if l.outputFormat == JSONOutputFormat {
+		l.emitJSON(level, lines)
 		return
 	}
 
-	for _, line := range debugColorize(fstring, args...) {
-		l.logger.Println(line)
+	for _, line := range lines {
+		l.logger.Println(line.colorized)
 	}
 }
 
-func (l *Logger) Debugln(msg string) {
-	if !l.IsDebug {
-		return
+// isVisible returns whether logs of this level are printed, based on IsDebug & IsQuiet.
+func (l *Logger) isVisible(level logLevel) bool {
+	switch level {
+	case debugLevel:
+		return l.IsDebug
+	case criticalLevel, plainLevel:
+		return true
+	default:
+		return !l.IsQuiet
 	}
+}
 
-	for _, line := range debugColorize(msg) {
-		l.logger.Println(line)
+// writeToDebugLog writes lines to the debug log (if set), without colors.
+func (l *Logger) writeToDebugLog(level logLevel, lines []lineToEmit) {
+	debugLogMutex.Lock()
+	defer debugLogMutex.Unlock()
+
+	if debugLogWriter == nil {
+		return
 	}
-}
 
-func (l *Logger) Plainf(fstring string, args ...interface{}) {
-	formattedString := fmt.Sprintf(fstring, args...)
+	timestamp := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+
+	l.secondaryPrefixesMutex.Lock()
+	prefix := l.prefix + l.formatSecondaryPrefixes()
+	l.secondaryPrefixesMutex.Unlock()
 
-	for _, line := range strings.Split(formattedString, "\n") {
-		l.logger.Println(line)
+	for _, line := range lines {
+		fmt.Fprintf(debugLogWriter, "%s %-8s %s%s\n", timestamp, level, prefix, line.plain)
 	}
 }
 
-func (l *Logger) Plainln(msg string) {
-	lines := strings.Split(msg, "\n")
+// emitJSON writes one JSON object per line. Colors aren't included, the level conveys the same information.
+func (l *Logger) emitJSON(level logLevel, lines []lineToEmit) {
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+
+	l.secondaryPrefixesMutex.Lock()
+	secondaryPrefixes := append([]string{}, l.secondaryPrefixes...)
+	l.secondaryPrefixesMutex.Unlock()
+
+	secondaryPrefix := ""
+	if len(secondaryPrefixes) > 0 {
+		secondaryPrefix = secondaryPrefixes[len(secondaryPrefixes)-1]
+	}
 
 	for _, line := range lines {
-		l.logger.Println(line)
+		encoded, err := json.Marshal(jsonLogLine{
+			Timestamp:         timestamp,
+			Level:             level,
+			Prefix:            strings.Trim(l.prefix, "[] "),
+			SecondaryPrefix:   secondaryPrefix,
+			SecondaryPrefixes: secondaryPrefixes,
+			Message:           line.plain,
+		})
+		if err != nil {
+			panic(fmt.Sprintf("CodeCrafters internal error: failed to encode log line: %s", err))
+		}
+
+		// A single write per line, so that lines from concurrent loggers don't interleave
+		l.logger.Writer().Write(append(encoded, '\n'))
 	}
 }
@@ -0,0 +1,188 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONOutputFormat(t *testing.T) {
+	SetDefaultOutputFormat(JSONOutputFormat)
+	defer SetDefaultOutputFormat(TextOutputFormat)
+
+	var output bytes.Buffer
+
+	l := GetLoggerWithWriter(&output, true, "[stage-1] ")
+
+	l.Infof("Running tests for %s", "Stage #1")
+	l.UpdateSecondaryPrefix("client-1")
+	l.Debugln("line 1\nline 2")
+	l.ResetSecondaryPrefix()
+	l.Plainln("100% done")
+
+	lines := strings.Split(strings.TrimRight(output.String(), "\n"), "\n")
+	if !assert.Len(t, lines, 4) {
+		t.FailNow()
+	}
+
+	parsedLines := make([]jsonLogLine, len(lines))
+	for i, line := range lines {
+		assert.NotContains(t, line, "\x1b[")
+
+		if !assert.NoError(t, json.Unmarshal([]byte(line), &parsedLines[i])) {
+			t.FailNow()
+		}
+
+		_, err := time.Parse(time.RFC3339Nano, parsedLines[i].Timestamp)
+		assert.NoError(t, err)
+		parsedLines[i].Timestamp = ""
+	}
+
+	assert.Equal(t, []jsonLogLine{
+		{Level: infoLevel, Prefix: "stage-1", SecondaryPrefix: "", SecondaryPrefixes: []string{}, Message: "Running tests for Stage #1"},
+		{Level: debugLevel, Prefix: "stage-1", SecondaryPrefix: "client-1", SecondaryPrefixes: []string{"client-1"}, Message: "line 1"},
+		{Level: debugLevel, Prefix: "stage-1", SecondaryPrefix: "client-1", SecondaryPrefixes: []string{"client-1"}, Message: "line 2"},
+		{Level: plainLevel, Prefix: "stage-1", SecondaryPrefix: "", SecondaryPrefixes: []string{}, Message: "100% done"},
+	}, parsedLines)
+}
+
+func TestTextOutputFormat(t *testing.T) {
+	SetDefaultColorPolicy(AlwaysColorPolicy)
+	defer SetDefaultColorPolicy(AutoColorPolicy)
+
+	var output bytes.Buffer
+
+	l := GetLoggerWithWriter(&output, false, "[stage-1] ")
+
+	l.Debugf("not shown")
+	l.Plainf("a\nb")
+
+	assert.Equal(t, "\x1b[33m[stage-1] \x1b[0ma\n\x1b[33m[stage-1] \x1b[0mb\n", output.String())
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	format, err := ParseOutputFormat("")
+	assert.NoError(t, err)
+	assert.Equal(t, TextOutputFormat, format)
+
+	format, err = ParseOutputFormat("json")
+	assert.NoError(t, err)
+	assert.Equal(t, JSONOutputFormat, format)
+
+	_, err = ParseOutputFormat("xml")
+	assert.Error(t, err)
+}
+
+func TestColorPolicy(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("FORCE_COLOR", "")
+
+	var output bytes.Buffer
+
+	// Buffers aren't terminals, so colors are disabled by default
+	GetLoggerWithWriter(&output, false, "[stage-1] ").Errorf("failed")
+	assert.Equal(t, "[stage-1] failed\n", output.String())
+
+	output.Reset()
+	t.Setenv("FORCE_COLOR", "1")
+	GetLoggerWithWriter(&output, false, "[stage-1] ").Errorf("failed")
+	assert.Equal(t, "\x1b[33m[stage-1] \x1b[0m\x1b[91mfailed\x1b[0m\n", output.String())
+
+	output.Reset()
+	t.Setenv("NO_COLOR", "1")
+	GetLoggerWithWriter(&output, false, "[stage-1] ").Errorf("failed")
+	assert.Equal(t, "[stage-1] failed\n", output.String())
+}
+
+func TestDefaultWriter(t *testing.T) {
+	SetDefaultColorPolicy(NeverColorPolicy)
+	defer SetDefaultColorPolicy(AutoColorPolicy)
+
+	var output bytes.Buffer
+
+	SetDefaultWriter(&output)
+	defer SetDefaultWriter(nil)
+
+	GetLogger(false, "").Infof("a")
+	GetQuietLogger("").Criticalf("b")
+	PrintBlankLine()
+
+	assert.Equal(t, "a\nb\n\n", output.String())
+}
+
+func TestDebugLog(t *testing.T) {
+	SetDefaultColorPolicy(AlwaysColorPolicy)
+	defer SetDefaultColorPolicy(AutoColorPolicy)
+
+	var output, debugLog bytes.Buffer
+
+	SetDebugLogWriter(&debugLog)
+	defer SetDebugLogWriter(nil)
+
+	l := GetLoggerWithWriter(&output, false, "[stage-1] ")
+	l.UpdateSecondaryPrefix("client-1")
+	l.Debugf("hidden")
+	l.Infof("visible")
+
+	// Anti-cheat logs are only included if they're visible
+	quietLogger := GetQuietLoggerWithWriter(&output, "")
+	quietLogger.Infof("anti-cheat detail")
+	quietLogger.Criticalf("anti-cheat failure")
+
+	assert.NotContains(t, output.String(), "hidden")
+
+	lines := strings.Split(strings.TrimRight(debugLog.String(), "\n"), "\n")
+	if !assert.Len(t, lines, 3) {
+		t.FailNow()
+	}
+
+	for _, line := range lines {
+		_, err := time.Parse("2006-01-02T15:04:05.000Z", strings.SplitN(line, " ", 2)[0])
+		assert.NoError(t, err)
+	}
+
+	assert.True(t, strings.HasSuffix(lines[0], " debug    [stage-1] [client-1] hidden"), lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], " info     [stage-1] [client-1] visible"), lines[1])
+	assert.True(t, strings.HasSuffix(lines[2], " critical anti-cheat failure"), lines[2])
+}
+
+func TestSecondaryPrefixStack(t *testing.T) {
+	SetDefaultColorPolicy(NeverColorPolicy)
+	defer SetDefaultColorPolicy(AutoColorPolicy)
+
+	var output bytes.Buffer
+
+	l := GetLoggerWithWriter(&output, false, "[stage-3] ")
+
+	l.PushSecondaryPrefix("replica-2")
+	err := l.WithSecondaryPrefix("handshake", func() error {
+		l.Infof("a")
+		return errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+	assert.Equal(t, "replica-2", l.GetSecondaryPrefix())
+
+	l.Infof("b")
+	l.PopSecondaryPrefix()
+	l.PopSecondaryPrefix() // No-op if there's nothing to pop
+	l.Infof("c")
+
+	// UpdateSecondaryPrefix replaces the whole stack
+	l.PushSecondaryPrefix("replica-1")
+	l.PushSecondaryPrefix("handshake")
+	l.UpdateSecondaryPrefix("client-1")
+	assert.Equal(t, "client-1", l.GetSecondaryPrefix())
+	l.Infof("d")
+	l.ResetSecondaryPrefix()
+	assert.Equal(t, "", l.GetSecondaryPrefix())
+
+	assert.Equal(t, "[stage-3] [replica-2] [handshake] a\n"+
+		"[stage-3] [replica-2] b\n"+
+		"[stage-3] c\n"+
+		"[stage-3] [client-1] d\n", output.String())
+}
@@ -0,0 +1,132 @@
+package test_case_harness
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// CreateScratchDir creates an empty temporary directory and sets it as the working directory of Executable (and of
+// executables created using NewExecutable after this). The directory is removed in RunTeardownFuncs.
+//
+// Useful for programs that create files, like a Git implementation:
+//
+//	scratchDir, err := harness.CreateScratchDir()
+//	if err != nil {
+//	    return err
+//	}
+//	result, err := harness.Executable.Run("init")
+//	// Check files in scratchDir...
+func (s *TestCaseHarness) CreateScratchDir() (string, error) {
+	scratchDir, err := os.MkdirTemp("", "tester-scratch-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+
+	s.mutex.Lock()
+	s.scratchDirs = append(s.scratchDirs, scratchDir)
+	s.mutex.Unlock()
+
+	s.Executable.WorkingDir = scratchDir
+
+	return scratchDir, nil
+}
+
+// CreateScratchDirFromFixture is like CreateScratchDir, but the directory starts with a copy of fixtureDir's contents.
+func (s *TestCaseHarness) CreateScratchDirFromFixture(fixtureDir string) (string, error) {
+	scratchDir, err := s.CreateScratchDir()
+	if err != nil {
+		return "", err
+	}
+
+	if err := copyDirContents(fixtureDir, scratchDir); err != nil {
+		return "", fmt.Errorf("failed to copy %s to scratch directory: %w", fixtureDir, err)
+	}
+
+	return scratchDir, nil
+}
+
+// CreateScratchDirFromRepository is like CreateScratchDir, but the directory starts with a copy of the user's
+// repository (RepositoryDir).
+func (s *TestCaseHarness) CreateScratchDirFromRepository() (string, error) {
+	if s.RepositoryDir == "" {
+		return "", errors.New("failed to create scratch directory: repository directory is not set")
+	}
+
+	return s.CreateScratchDirFromFixture(s.RepositoryDir)
+}
+
+func (s *TestCaseHarness) removeScratchDirs() {
+	s.mutex.Lock()
+	scratchDirs := s.scratchDirs
+	s.scratchDirs = nil
+	s.mutex.Unlock()
+
+	for _, scratchDir := range scratchDirs {
+		os.RemoveAll(scratchDir)
+	}
+}
+
+// copyDirContents copies files, directories & symlinks in sourceDir to destinationDir, preserving permissions.
+func copyDirContents(sourceDir string, destinationDir string) error {
+	return filepath.WalkDir(sourceDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+
+		destinationPath := filepath.Join(destinationDir, relativePath)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case entry.IsDir():
+			if relativePath == "." {
+				return nil
+			}
+
+			return os.Mkdir(destinationPath, info.Mode().Perm())
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			return os.Symlink(target, destinationPath)
+		case info.Mode().IsRegular():
+			return copyFile(path, destinationPath, info.Mode().Perm())
+		default:
+			return nil // Sockets, devices etc. aren't copied
+		}
+	})
+}
+
+func copyFile(sourcePath string, destinationPath string, perm fs.FileMode) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := os.OpenFile(destinationPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(destination, source); err != nil {
+		destination.Close()
+		return err
+	}
+
+	return destination.Close()
+}
@@ -0,0 +1,103 @@
+package test_case_harness
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/debanandanayak/tester-utils/executable"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateScratchDir(t *testing.T) {
+	harness := &TestCaseHarness{Executable: executable.NewExecutable("./test_helpers/fixture/nested/run.sh")}
+
+	scratchDir, err := harness.CreateScratchDir()
+	assert.NoError(t, err)
+	assert.Equal(t, scratchDir, harness.Executable.WorkingDir)
+	assert.Equal(t, scratchDir, harness.NewExecutable().WorkingDir)
+
+	entries, err := os.ReadDir(scratchDir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	harness.RunTeardownFuncs()
+
+	_, err = os.Stat(scratchDir)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCreateScratchDirFromFixture(t *testing.T) {
+	harness := &TestCaseHarness{Executable: executable.NewExecutable("./test_helpers/fixture/nested/run.sh")}
+
+	scratchDir, err := harness.CreateScratchDirFromFixture("./test_helpers/fixture")
+	assert.NoError(t, err)
+
+	contents, err := os.ReadFile(filepath.Join(scratchDir, "greeting.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(contents))
+
+	linkTarget, err := os.Readlink(filepath.Join(scratchDir, "link.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "greeting.txt", linkTarget)
+
+	info, err := os.Stat(filepath.Join(scratchDir, "nested", "run.sh"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm()&0755)
+
+	// The program runs in the scratch directory
+	result, err := harness.Executable.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello from "+filepath.Base(scratchDir)+"\n", string(result.Stdout))
+
+	// Teardown functions run before the directory is removed
+	harness.RegisterTeardownFunc(func() {
+		_, err := os.Stat(scratchDir)
+		assert.NoError(t, err)
+	})
+	harness.RunTeardownFuncs()
+
+	_, err = os.Stat(scratchDir)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCreateScratchDirFromRepository(t *testing.T) {
+	// Programs found on PATH are outside the repository, so it can't be guessed from the program's path
+	harness := &TestCaseHarness{Executable: executable.NewExecutable("bash"), RepositoryDir: "./test_helpers/fixture"}
+	defer harness.RunTeardownFuncs()
+
+	scratchDir, err := harness.CreateScratchDirFromRepository()
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(scratchDir, "nested", "run.sh"))
+	assert.NoError(t, err)
+
+	harness.RepositoryDir = ""
+	_, err = harness.CreateScratchDirFromRepository()
+	assert.EqualError(t, err, "failed to create scratch directory: repository directory is not set")
+}
+
+func TestScratchDirIsRemovedAfterLeakedProcessesAreKilled(t *testing.T) {
+	harness := &TestCaseHarness{Executable: executable.NewExecutable("bash")}
+
+	scratchDir, err := harness.CreateScratchDir()
+	assert.NoError(t, err)
+
+	// Records whether the scratch directory was removed while it was still running
+	markerPath := filepath.Join(t.TempDir(), "removed.txt")
+	_, err = harness.Executable.Run("-c", `(while [ -d "$PWD" ]; do sleep 0.01; done; echo removed > "$0") >/dev/null 2>&1 &`, markerPath)
+	assert.NoError(t, err)
+
+	harness.RunTeardownFuncs()
+
+	_, err = os.Stat(scratchDir)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	time.Sleep(100 * time.Millisecond)
+	_, err = os.Stat(markerPath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.NotEmpty(t, harness.KillLeakedProcesses())
+	assert.Empty(t, harness.KillLeakedProcesses())
+}
@@ -1,6 +1,9 @@
 package test_case_harness
 
 import (
+	"sync"
+	"time"
+
 	"github.com/debanandanayak/tester-utils/executable"
 	"github.com/debanandanayak/tester-utils/logger"
 )
@@ -28,20 +31,122 @@ type TestCaseHarness struct {
 	// Executable is the program to be tested.
 	Executable *executable.Executable
 
+	// RepositoryDir is the directory that contains the user's code. Example: CreateScratchDirFromRepository copies it.
+	RepositoryDir string
+
 	// teardownFuncs are run once the error has been reported to the user
 	teardownFuncs []func()
+
+	// scratchDirs are removed after teardownFuncs are run, so that programs started in them are stopped first
+	scratchDirs []string
+
+	// executables are the ones created using NewExecutable, they're checked for leaked processes along with Executable
+	executables []*executable.Executable
+
+	// killedLeakedProcesses were killed by RunTeardownFuncs, they're reported by KillLeakedProcesses
+	killedLeakedProcesses []executable.LeakedProcess
+
+	// mutex guards teardownFuncs, scratchDirs, executables & killedLeakedProcesses. If a test times out, teardown runs
+	// while the test function might still be running.
+	mutex sync.Mutex
 }
 
 func (s *TestCaseHarness) RegisterTeardownFunc(teardownFunc func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	s.teardownFuncs = append(s.teardownFuncs, teardownFunc)
 }
 
 func (s *TestCaseHarness) RunTeardownFuncs() {
-	for _, teardownFunc := range s.teardownFuncs {
+	s.mutex.Lock()
+	teardownFuncs := s.teardownFuncs
+	s.teardownFuncs = nil
+	s.mutex.Unlock()
+
+	for _, teardownFunc := range teardownFuncs {
 		teardownFunc()
 	}
+
+	// Leaked processes can still be using the scratch directories, so they're killed first
+	leakedProcesses := s.findAndKillLeakedProcesses()
+
+	s.mutex.Lock()
+	s.killedLeakedProcesses = append(s.killedLeakedProcesses, leakedProcesses...)
+	s.mutex.Unlock()
+
+	s.removeScratchDirs()
 }
 
 func (s *TestCaseHarness) NewExecutable() *executable.Executable {
-	return s.Executable.Clone()
+	newExecutable := s.Executable.Clone()
+
+	s.mutex.Lock()
+	s.executables = append(s.executables, newExecutable)
+	s.mutex.Unlock()
+
+	return newExecutable
+}
+
+// KillLeakedProcesses kills processes that programs run in this test case left running, and returns them. Leaked
+// processes that RunTeardownFuncs already killed are included. See executable.Executable.KillLeakedProcesses for details.
+func (s *TestCaseHarness) KillLeakedProcesses() []executable.LeakedProcess {
+	s.mutex.Lock()
+	killedLeakedProcesses := s.killedLeakedProcesses
+	s.killedLeakedProcesses = nil
+	s.mutex.Unlock()
+
+	return append(killedLeakedProcesses, s.findAndKillLeakedProcesses()...)
+}
+
+func (s *TestCaseHarness) findAndKillLeakedProcesses() []executable.LeakedProcess {
+	s.mutex.Lock()
+	executables := append([]*executable.Executable{s.Executable}, s.executables...)
+	s.mutex.Unlock()
+
+	leakedProcesses := []executable.LeakedProcess{}
+	for _, executable := range executables {
+		leakedProcesses = append(leakedProcesses, executable.KillLeakedProcesses()...)
+	}
+
+	return leakedProcesses
+}
+
+// PauseExecutableFor freezes a running program (and any processes it started) for duration, and then resumes it. Time
+// spent paused doesn't count towards the program's timeout, but does count towards the test case's timeout.
+//
+// Useful for testing how other nodes react when one stops responding, like a leader that misses heartbeats:
+//
+//	if err := harness.PauseExecutableFor(leader, 2*time.Second); err != nil {
+//	    return err
+//	}
+func (s *TestCaseHarness) PauseExecutableFor(executable *executable.Executable, duration time.Duration) error {
+	if err := executable.Pause(); err != nil {
+		return err
+	}
+
+	time.Sleep(duration)
+
+	return executable.Resume()
+}
+
+// LogTerminationExplanation logs a user-friendly explanation if the program terminated abnormally (crashed, was killed
+// or timed out). Nothing is logged if the program exited by itself.
+func (s *TestCaseHarness) LogTerminationExplanation(result executable.ExecutableResult) {
+	if explanation := result.TerminationExplanation(); explanation != "" {
+		s.Logger.Errorln(explanation)
+	}
+}
+
+// LogResourceSamples logs the resources the program used over time (in debug mode only), one line per sample. Useful
+// for debugging leaks, like file descriptors growing with each connection.
+func (s *TestCaseHarness) LogResourceSamples(samples []executable.ResourceSample) {
+	if len(samples) == 0 {
+		return
+	}
+
+	s.Logger.Debugf("Resource usage timeline:")
+	for _, sample := range samples {
+		s.Logger.Debugf("  %s", sample)
+	}
 }
@@ -0,0 +1,29 @@
+package test_case_harness
+
+import (
+	"testing"
+	"time"
+
+	"github.com/debanandanayak/tester-utils/executable"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPauseExecutableFor(t *testing.T) {
+	harness := &TestCaseHarness{Executable: executable.NewExecutable("bash")}
+	harness.Executable.TimeoutInMilliseconds = 300
+
+	err := harness.Executable.Start("-c", "sleep 0.2; echo done")
+	assert.NoError(t, err)
+
+	startTime := time.Now()
+	err = harness.PauseExecutableFor(harness.Executable, 400*time.Millisecond)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(startTime), 400*time.Millisecond)
+
+	result, err := harness.Executable.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, "done\n", string(result.Stdout))
+
+	err = harness.PauseExecutableFor(harness.Executable, time.Millisecond)
+	assert.EqualError(t, err, "program is not running")
+}
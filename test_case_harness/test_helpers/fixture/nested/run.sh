@@ -0,0 +1,2 @@
+#!/bin/sh
+echo "$(cat greeting.txt) from $(basename "$PWD")"
@@ -0,0 +1,4 @@
+#!/bin/sh
+
+echo "error: expected expression" 1>&2
+exit 1
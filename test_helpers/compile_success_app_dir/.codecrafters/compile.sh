@@ -0,0 +1,5 @@
+#!/bin/sh
+
+# Fails unless run from the repository directory
+test -f codecrafters.yml || exit 1
+echo "compiling..."
@@ -0,0 +1,29 @@
+package test_runner
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// stepLogBuffer holds a step's logs when TestRunner.ShouldBufferLogs is set. Writes can come from multiple goroutines
+// (the test function, and relays for the program's output).
+type stepLogBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *stepLogBuffer) Write(bytes []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.Write(bytes)
+}
+
+// replay writes everything buffered so far to writer.
+func (b *stepLogBuffer) replay(writer io.Writer) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	writer.Write(b.buffer.Bytes())
+}
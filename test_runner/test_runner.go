@@ -25,6 +25,17 @@ type TestRunnerStep struct {
 type TestRunner struct {
 	isQuiet bool // Used for anti-cheat tests, where we only want Critical logs to be emitted
 	steps   []TestRunnerStep
+
+	// ShouldFailOnLeakedProcesses makes a step fail if the program leaves processes running after the step. By default,
+	// a warning is logged.
+	ShouldFailOnLeakedProcesses bool
+
+	// RepositoryDir is the directory that contains the user's code, it is passed on to test cases using TestCaseHarness.
+	RepositoryDir string
+
+	// ShouldBufferLogs holds back each step's logs (including the program's output) until the step finishes. If the step
+	// passes, only a one-line summary is printed. If it fails, all of the step's logs are printed.
+	ShouldBufferLogs bool
 }
 
 func NewTestRunner(steps []TestRunnerStep) TestRunner {
@@ -40,48 +51,107 @@ func NewQuietTestRunner(steps []TestRunnerStep) TestRunner {
 // Run runs all tests in a stageRunner
 func (r TestRunner) Run(isDebug bool, executable *executable.Executable) bool {
 	for index, step := range r.steps {
-		if index != 0 {
-			fmt.Println("")
+		var err error
+
+		if r.ShouldBufferLogs {
+			err = r.runBufferedStep(index, isDebug, executable, step)
+		} else {
+			if index != 0 {
+				logger.PrintBlankLine()
+			}
+
+			_, err = r.runStep(r.getLoggerForStep(isDebug, step), isDebug, executable, step)
 		}
 
-		testCaseHarness := test_case_harness.TestCaseHarness{
-			Logger:     r.getLoggerForStep(isDebug, step),
-			Executable: executable.Clone(),
+		if err != nil {
+			return false
 		}
+	}
+
+	return true
+}
 
-		logger := testCaseHarness.Logger
-		logger.Infof("Running tests for %s", step.Title)
+// runBufferedStep runs a step with all logs (written using loggers from logger.GetLogger) going to a buffer. If the step
+// fails or logs a warning, the buffered logs are printed. Otherwise, a summary is printed instead.
+func (r TestRunner) runBufferedStep(index int, isDebug bool, executable *executable.Executable, step TestRunnerStep) error {
+	// The logger is created before logs are redirected, so that colors are decided based on where logs end up
+	stepLogger := r.getLoggerForStep(isDebug, step)
 
-		stepResultChannel := make(chan error, 1)
-		go func() {
-			err := step.TestCase.TestFunc(&testCaseHarness)
-			stepResultChannel <- err
-		}()
+	logBuffer := &stepLogBuffer{}
+	previousWriter := logger.SetDefaultWriter(logBuffer)
 
-		timeout := step.TestCase.CustomOrDefaultTimeout()
+	hasLoggedWarnings, err := r.runStep(stepLogger, isDebug, executable, step)
 
-		var err error
-		select {
-		case stageErr := <-stepResultChannel:
-			err = stageErr
-		case <-time.After(timeout):
-			err = fmt.Errorf("timed out, test exceeded %d seconds", int64(timeout.Seconds()))
+	logger.SetDefaultWriter(previousWriter)
+
+	// Warnings (like leaked processes) are printed along with the rest of the step's logs, for context
+	if err != nil || hasLoggedWarnings {
+		if index != 0 {
+			logger.PrintBlankLine()
 		}
 
-		if err != nil {
+		logBuffer.replay(logger.DefaultWriter())
+	}
+
+	if err == nil {
+		stepLogger.Successf("Test passed. (%s)", step.Title)
+	}
+
+	return err
+}
+
+// runStep runs a step, and returns an error if it fails. The error has already been logged. hasLoggedWarnings is true if
+// a warning was logged, even if the step passed.
+func (r TestRunner) runStep(logger *logger.Logger, isDebug bool, executable *executable.Executable, step TestRunnerStep) (hasLoggedWarnings bool, err error) {
+	testCaseHarness := test_case_harness.TestCaseHarness{
+		Logger:        logger,
+		Executable:    executable.Clone(),
+		RepositoryDir: r.RepositoryDir,
+	}
+
+	logger.Infof("Running tests for %s", step.Title)
+
+	stepResultChannel := make(chan error, 1)
+	go func() {
+		err := step.TestCase.TestFunc(&testCaseHarness)
+		stepResultChannel <- err
+	}()
+
+	timeout := step.TestCase.CustomOrDefaultTimeout()
+
+	select {
+	case stageErr := <-stepResultChannel:
+		err = stageErr
+	case <-time.After(timeout):
+		err = fmt.Errorf("timed out, test exceeded %d seconds", int64(timeout.Seconds()))
+	}
+
+	if err != nil {
+		r.reportTestError(err, isDebug, logger)
+	} else if !r.ShouldFailOnLeakedProcesses && !r.ShouldBufferLogs {
+		logger.Successf("Test passed.")
+	}
+
+	testCaseHarness.RunTeardownFuncs()
+
+	if leakedProcesses := testCaseHarness.KillLeakedProcesses(); len(leakedProcesses) > 0 {
+		if r.ShouldFailOnLeakedProcesses && err == nil {
+			err = fmt.Errorf("Your program left %s running after the test finished", pluralizeProcesses(len(leakedProcesses)))
+			logger.Infof("Killed %s that your program left running after the test finished:", pluralizeProcesses(len(leakedProcesses)))
+			r.reportLeakedProcesses(leakedProcesses, logger)
 			r.reportTestError(err, isDebug, logger)
 		} else {
-			logger.Successf("Test passed.")
+			hasLoggedWarnings = true
+			logger.Infof("Warning: Killed %s that your program left running after the test finished:", pluralizeProcesses(len(leakedProcesses)))
+			r.reportLeakedProcesses(leakedProcesses, logger)
 		}
+	}
 
-		testCaseHarness.RunTeardownFuncs()
-
-		if err != nil {
-			return false
-		}
+	if err == nil && r.ShouldFailOnLeakedProcesses && !r.ShouldBufferLogs {
+		logger.Successf("Test passed.")
 	}
 
-	return true
+	return hasLoggedWarnings, err
 }
 
 func (r TestRunner) getLoggerForStep(isDebug bool, step TestRunnerStep) *logger.Logger {
@@ -103,6 +173,22 @@ func (r TestRunner) reportTestError(err error, isDebug bool, logger *logger.Logg
 	}
 }
 
+func (r TestRunner) reportLeakedProcesses(leakedProcesses []executable.LeakedProcess, logger *logger.Logger) {
+	for _, leakedProcess := range leakedProcesses {
+		logger.Infof("  - %s", leakedProcess)
+	}
+
+	logger.Infof("Processes that keep running (like background workers) can hold on to ports and cause later tests to fail.")
+}
+
+func pluralizeProcesses(count int) string {
+	if count == 1 {
+		return "1 process"
+	}
+
+	return fmt.Sprintf("%d processes", count)
+}
+
 // Fuck you, go
 func min(a, b int) int {
 	if a < b {
@@ -2,6 +2,8 @@ package tester_utils
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/debanandanayak/tester-utils/executable"
 	"github.com/debanandanayak/tester-utils/internal"
@@ -44,16 +46,35 @@ func newTester(env map[string]string, definition tester_definition.TesterDefinit
 func RunCLI(env map[string]string, definition tester_definition.TesterDefinition) int {
 	random.Init()
 
+	// Set before creating the tester, so that errors in the tester context are printed in the requested format too. An
+	// invalid format is reported by newTester.
+	if logFormat, err := logger.ParseOutputFormat(env["CODECRAFTERS_LOG_FORMAT"]); err == nil {
+		logger.SetDefaultOutputFormat(logFormat)
+	}
+
 	tester, err := newTester(env, definition)
 	if err != nil {
-		fmt.Println(err.Error())
+		logger.GetLogger(false, "").Errorln(err.Error())
+		return 1
+	}
+
+	testerLogger := logger.GetLogger(tester.context.IsDebug, "")
+
+	closeDebugLog, err := tester.openDebugLog()
+	if err != nil {
+		testerLogger.Errorln(err.Error())
 		return 1
 	}
+	defer closeDebugLog()
 
-	tester.printDebugContext()
+	tester.printDebugContext(testerLogger)
 
 	// TODO: Validate context here instead of in NewTester?
 
+	if !tester.runCompileStep() {
+		return 1
+	}
+
 	if !tester.runStages() {
 		return 1
 	}
@@ -66,13 +87,83 @@ func RunCLI(env map[string]string, definition tester_definition.TesterDefinition
 }
 
 // PrintDebugContext is to be run as early as possible after creating a Tester
-func (tester Tester) printDebugContext() {
-	if !tester.context.IsDebug {
-		return
+func (tester Tester) printDebugContext(testerLogger *logger.Logger) {
+	tester.context.Print(testerLogger)
+
+	if tester.context.IsDebug {
+		logger.PrintBlankLine()
 	}
+}
 
-	tester.context.Print()
-	fmt.Println("")
+// openDebugLog starts writing the debug log (every log, regardless of whether debug mode is on) to DebugLogPath, if set.
+// The returned function must be called once the tester is done.
+func (tester Tester) openDebugLog() (closeFunc func(), err error) {
+	if tester.context.DebugLogPath == "" {
+		return func() {}, nil
+	}
+
+	file, err := os.Create(tester.context.DebugLogPath)
+	if err != nil {
+		return nil, fmt.Errorf("CodeCrafters internal error. Error creating debug log: %v", err)
+	}
+
+	logger.SetDebugLogWriter(file)
+
+	return func() {
+		logger.SetDebugLogWriter(nil)
+		file.Close()
+	}, nil
+}
+
+// runCompileStep runs the compile script specified in the TesterDefinition (if present in the user's repository), so
+// that build time doesn't count towards the first stage's timeout. Returns true if compilation succeeds.
+func (tester Tester) runCompileStep() bool {
+	if tester.context.CompileScriptPath == "" {
+		return true
+	}
+
+	compileLogger := logger.GetLogger(tester.context.IsDebug, "[compile] ")
+	compileLogger.Infof("Compiling your program using %s", tester.definition.CompileScriptFileName)
+
+	// The compiler's output is streamed in debug mode, otherwise it is only printed if compilation fails
+	var compileExecutable *executable.Executable
+	if tester.context.IsDebug {
+		compileExecutable = executable.NewVerboseExecutable(tester.context.CompileScriptPath, compileLogger.Plainln)
+	} else {
+		compileExecutable = executable.NewExecutable(tester.context.CompileScriptPath)
+	}
+
+	compileTimeout := tester.definition.CustomOrDefaultCompileTimeout()
+	compileExecutable.TimeoutInMilliseconds = int(compileTimeout.Milliseconds())
+	compileExecutable.WorkingDir = tester.context.RepositoryDir
+
+	result, err := compileExecutable.Run()
+	output := strings.TrimRight(string(result.Stdout)+string(result.Stderr), "\n")
+
+	if err == nil && result.ExitCode == 0 {
+		// Not visible unless debug mode is on (in which case it was streamed already), but included in the debug log
+		if output != "" && !tester.context.IsDebug {
+			compileLogger.Debugf("%s", output)
+		}
+
+		compileLogger.Successf("Compilation successful.")
+		logger.PrintBlankLine()
+		return true
+	}
+
+	if output != "" && !tester.context.IsDebug {
+		compileLogger.Plainln(output)
+	}
+
+	if result.TimedOut {
+		compileLogger.Errorf("Compilation timed out, exceeded %d seconds", int64(compileTimeout.Seconds()))
+	} else if err != nil {
+		compileLogger.Errorf("Failed to run %s: %s", tester.definition.CompileScriptFileName, err)
+	} else {
+		compileLogger.Errorf("Compilation failed (%s exited with code %d)", tester.definition.CompileScriptFileName, result.ExitCode)
+	}
+
+	return false
 }
 
 // runAntiCheatStages runs any anti-cheat stages specified in the TesterDefinition. Only critical logs are emitted. If
@@ -99,7 +190,12 @@ func (tester Tester) getRunner() test_runner.TestRunner {
 		})
 	}
 
-	return test_runner.NewTestRunner(steps)
+	runner := test_runner.NewTestRunner(steps)
+	runner.ShouldFailOnLeakedProcesses = tester.definition.ShouldFailOnLeakedProcesses
+	runner.RepositoryDir = tester.context.RepositoryDir
+	runner.ShouldBufferLogs = tester.context.ShouldBufferStageLogs
+
+	return runner
 }
 
 func (tester Tester) getAntiCheatRunner() test_runner.TestRunner {
@@ -113,7 +209,10 @@ func (tester Tester) getAntiCheatRunner() test_runner.TestRunner {
 		})
 	}
 
-	return test_runner.NewQuietTestRunner(steps) // We only want Critical logs to be emitted for anti-cheat tests
+	runner := test_runner.NewQuietTestRunner(steps) // We only want Critical logs to be emitted for anti-cheat tests
+	runner.RepositoryDir = tester.context.RepositoryDir
+
+	return runner
 }
 
 func (tester Tester) getQuietExecutable() *executable.Executable {
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 
 	"github.com/debanandanayak/tester-utils/internal"
+	"github.com/debanandanayak/tester-utils/logger"
 	"github.com/debanandanayak/tester-utils/tester_definition"
 	"gopkg.in/yaml.v2"
 )
@@ -27,17 +29,23 @@ type TesterContextTestCase struct {
 // TesterContext holds all flags passed in via environment variables, or from the codecrafters.yml file
 type TesterContext struct {
 	ExecutablePath               string
+	RepositoryDir                string
+	CompileScriptPath            string // Empty if the tester doesn't use a compile script, or the file isn't present
 	IsDebug                      bool
 	TestCases                    []TesterContextTestCase
 	ShouldSkipAntiCheatTestCases bool
+	LogFormat                    logger.OutputFormat // From CODECRAFTERS_LOG_FORMAT, "text" (default) or "json"
+	ShouldBufferStageLogs        bool                // From CODECRAFTERS_BUFFER_STAGE_LOGS, only failing stages' logs are printed
+	DebugLogPath                 string              // From CODECRAFTERS_DEBUG_LOG_PATH, empty if a debug log shouldn't be written
 }
 
 type yamlConfig struct {
 	Debug bool `yaml:"debug"`
 }
 
-func (c TesterContext) Print() {
-	fmt.Println("Debug =", c.IsDebug)
+// Print logs the context at debug level, so that it's always written to the debug log.
+func (c TesterContext) Print(l *logger.Logger) {
+	l.Debugf("Debug = %v", c.IsDebug)
 }
 
 // GetContext parses flags and returns a Context object
@@ -64,6 +72,13 @@ func GetTesterContext(env map[string]string, definition tester_definition.Tester
 		shouldSkipAntiCheatTestCases = true
 	}
 
+	shouldBufferStageLogs := env["CODECRAFTERS_BUFFER_STAGE_LOGS"] == "true"
+
+	logFormat, err := logger.ParseOutputFormat(env["CODECRAFTERS_LOG_FORMAT"])
+	if err != nil {
+		return TesterContext{}, fmt.Errorf("invalid CODECRAFTERS_LOG_FORMAT: %s", err)
+	}
+
 	for _, testCase := range testCases {
 		if testCase.Slug == "" {
 			return TesterContext{}, fmt.Errorf("CODECRAFTERS_TEST_CASES_JSON contains a test case with an empty slug")
@@ -91,6 +106,20 @@ func GetTesterContext(env map[string]string, definition tester_definition.Tester
 		executablePath = legacyExecutablePath
 	}
 
+	compileScriptPath := ""
+
+	if definition.CompileScriptFileName != "" {
+		// The compile script is run from the repository directory, so a relative path wouldn't resolve
+		absoluteCompileScriptPath, err := filepath.Abs(path.Join(submissionDir, definition.CompileScriptFileName))
+		if err != nil {
+			return TesterContext{}, err
+		}
+
+		if _, err := os.Stat(absoluteCompileScriptPath); err == nil {
+			compileScriptPath = absoluteCompileScriptPath
+		}
+	}
+
 	configPath := path.Join(submissionDir, "codecrafters.yml")
 
 	yamlConfig, err := readFromYAML(configPath)
@@ -106,9 +135,14 @@ func GetTesterContext(env map[string]string, definition tester_definition.Tester
 
 	return TesterContext{
 		ExecutablePath:               executablePath,
+		RepositoryDir:                submissionDir,
+		CompileScriptPath:            compileScriptPath,
 		IsDebug:                      yamlConfig.Debug,
 		TestCases:                    testCases,
 		ShouldSkipAntiCheatTestCases: shouldSkipAntiCheatTestCases,
+		LogFormat:                    logFormat,
+		ShouldBufferStageLogs:        shouldBufferStageLogs,
+		DebugLogPath:                 env["CODECRAFTERS_DEBUG_LOG_PATH"],
 	}, nil
 }
 
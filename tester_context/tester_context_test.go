@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/debanandanayak/tester-utils/logger"
 	"github.com/debanandanayak/tester-utils/tester_definition"
 	"github.com/stretchr/testify/assert"
 )
@@ -67,3 +68,23 @@ func TestCorrectExecutable(t *testing.T) {
 		assert.Equal(t, context.ExecutablePath, fmt.Sprintf("test_helpers/%s/%s", tt.submissionDir, tt.expectedExecutable))
 	}
 }
+
+func TestLogFormat(t *testing.T) {
+	context, err := GetTesterContext(map[string]string{
+		"CODECRAFTERS_TEST_CASES_JSON": `[{ "slug": "test", "tester_log_prefix": "test", "title": "Test"}]`,
+		"CODECRAFTERS_REPOSITORY_DIR":  "./test_helpers/valid_app_dir",
+		"CODECRAFTERS_LOG_FORMAT":      "json",
+	}, tester_definition.TesterDefinition{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, logger.JSONOutputFormat, context.LogFormat)
+
+	_, err = GetTesterContext(map[string]string{
+		"CODECRAFTERS_TEST_CASES_JSON": `[{ "slug": "test", "tester_log_prefix": "test", "title": "Test"}]`,
+		"CODECRAFTERS_REPOSITORY_DIR":  "./test_helpers/valid_app_dir",
+		"CODECRAFTERS_LOG_FORMAT":      "xml",
+	}, tester_definition.TesterDefinition{})
+	assert.Error(t, err)
+}
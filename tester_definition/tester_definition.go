@@ -35,10 +35,29 @@ type TesterDefinition struct {
 	ExecutableFileName       string
 	LegacyExecutableFileName string
 
+	// CompileScriptFileName is optional. If set and the file is present in the user's repository, it is run once before
+	// any test case. Example: .codecrafters/compile.sh
+	CompileScriptFileName string
+
+	// CompileTimeout is the maximum amount of time that the compile script can run for.
+	CompileTimeout time.Duration
+
+	// ShouldFailOnLeakedProcesses makes a test case fail if the user's program leaves processes running after the test
+	// case is done (like a background worker). By default, a warning is logged. Leaked processes are killed either way.
+	ShouldFailOnLeakedProcesses bool
+
 	TestCases          []TestCase
 	AntiCheatTestCases []TestCase
 }
 
+func (t TesterDefinition) CustomOrDefaultCompileTimeout() time.Duration {
+	if t.CompileTimeout == 0 {
+		return 2 * time.Minute
+	} else {
+		return t.CompileTimeout
+	}
+}
+
 func (t TesterDefinition) TestCaseBySlug(slug string) TestCase {
 	for _, testCase := range t.TestCases {
 		if testCase.Slug == slug {
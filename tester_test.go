@@ -1,11 +1,15 @@
 package tester_utils
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/debanandanayak/tester-utils/logger"
 	"github.com/debanandanayak/tester-utils/test_case_harness"
 	"github.com/debanandanayak/tester-utils/tester_definition"
 	"github.com/stretchr/testify/assert"
@@ -64,3 +68,180 @@ func TestOneStageFails(t *testing.T) {
 	exitCode := RunCLI(env, definition)
 	assert.Equal(t, exitCode, 1)
 }
+
+func TestCompileScript(t *testing.T) {
+	testFuncCalled := false
+
+	definition := tester_definition.TesterDefinition{
+		CompileScriptFileName: ".codecrafters/compile.sh",
+		TestCases: []tester_definition.TestCase{
+			{Slug: "test-1", TestFunc: func(harness *test_case_harness.TestCaseHarness) error {
+				testFuncCalled = true
+				return nil
+			}},
+		},
+	}
+
+	env := map[string]string{
+		"CODECRAFTERS_REPOSITORY_DIR":  "./test_helpers/compile_success_app_dir",
+		"CODECRAFTERS_TEST_CASES_JSON": buildTestCasesJson([]string{"test-1"}),
+	}
+	exitCode := RunCLI(env, definition)
+	assert.Equal(t, 0, exitCode)
+	assert.True(t, testFuncCalled)
+
+	// Stages aren't run if compilation fails
+	testFuncCalled = false
+	env["CODECRAFTERS_REPOSITORY_DIR"] = "./test_helpers/compile_failure_app_dir"
+	exitCode = RunCLI(env, definition)
+	assert.Equal(t, 1, exitCode)
+	assert.False(t, testFuncCalled)
+
+	// The compile script is optional
+	env["CODECRAFTERS_REPOSITORY_DIR"] = "./test_helpers/valid_app_dir"
+	exitCode = RunCLI(env, definition)
+	assert.Equal(t, 0, exitCode)
+	assert.True(t, testFuncCalled)
+}
+
+func TestLeakedProcesses(t *testing.T) {
+	leakFunc := func(harness *test_case_harness.TestCaseHarness) error {
+		harness.Executable.Path = "bash"
+		_, err := harness.Executable.Run("-c", "sleep 60 >/dev/null 2>&1 &")
+		return err
+	}
+
+	definition := tester_definition.TesterDefinition{
+		TestCases: []tester_definition.TestCase{
+			{Slug: "test-1", TestFunc: leakFunc},
+		},
+	}
+
+	env := map[string]string{
+		"CODECRAFTERS_REPOSITORY_DIR":  "./test_helpers/valid_app_dir",
+		"CODECRAFTERS_TEST_CASES_JSON": buildTestCasesJson([]string{"test-1"}),
+	}
+
+	// A warning is logged by default
+	exitCode := RunCLI(env, definition)
+	assert.Equal(t, 0, exitCode)
+
+	// The warning isn't hidden when logs of passing stages are buffered
+	var output bytes.Buffer
+	logger.SetDefaultWriter(&output)
+
+	env["CODECRAFTERS_BUFFER_STAGE_LOGS"] = "true"
+	exitCode = RunCLI(env, definition)
+	assert.Equal(t, 0, exitCode)
+	assert.Contains(t, output.String(), "Warning: Killed 1 process that your program left running after the test finished:")
+	assert.Contains(t, output.String(), "Test passed. (Stage #1: test-1)")
+
+	logger.SetDefaultWriter(nil)
+	delete(env, "CODECRAFTERS_BUFFER_STAGE_LOGS")
+
+	definition.ShouldFailOnLeakedProcesses = true
+	exitCode = RunCLI(env, definition)
+	assert.Equal(t, 1, exitCode)
+}
+
+func TestBufferedStageLogs(t *testing.T) {
+	definition := tester_definition.TesterDefinition{
+		TestCases: []tester_definition.TestCase{
+			{Slug: "test-1", TestFunc: func(harness *test_case_harness.TestCaseHarness) error {
+				harness.Logger.Infof("Log from passing stage")
+				return nil
+			}},
+			{Slug: "test-2", TestFunc: func(harness *test_case_harness.TestCaseHarness) error {
+				harness.Logger.Infof("Log from failing stage")
+				return errors.New("fail")
+			}},
+		},
+	}
+
+	env := map[string]string{
+		"CODECRAFTERS_REPOSITORY_DIR":    "./test_helpers/valid_app_dir",
+		"CODECRAFTERS_TEST_CASES_JSON":   buildTestCasesJson([]string{"test-1", "test-2"}),
+		"CODECRAFTERS_BUFFER_STAGE_LOGS": "true",
+	}
+
+	var output bytes.Buffer
+	logger.SetDefaultWriter(&output)
+	defer logger.SetDefaultWriter(nil)
+
+	exitCode := RunCLI(env, definition)
+	assert.Equal(t, 1, exitCode)
+
+	assert.Contains(t, output.String(), "Test passed. (Stage #1: test-1)")
+	assert.NotContains(t, output.String(), "Log from passing stage")
+	assert.Contains(t, output.String(), "Log from failing stage")
+	assert.Contains(t, output.String(), "Test failed")
+}
+
+func TestDebugLog(t *testing.T) {
+	definition := tester_definition.TesterDefinition{
+		TestCases: []tester_definition.TestCase{
+			{Slug: "test-1", TestFunc: func(harness *test_case_harness.TestCaseHarness) error {
+				harness.Logger.Debugf("Debug log from stage")
+				return nil
+			}},
+		},
+	}
+
+	debugLogPath := filepath.Join(t.TempDir(), "debug.log")
+
+	env := map[string]string{
+		"CODECRAFTERS_REPOSITORY_DIR":  "./test_helpers/valid_app_dir",
+		"CODECRAFTERS_TEST_CASES_JSON": buildTestCasesJson([]string{"test-1"}),
+		"CODECRAFTERS_DEBUG_LOG_PATH":  debugLogPath,
+	}
+
+	exitCode := RunCLI(env, definition)
+	assert.Equal(t, 0, exitCode)
+
+	debugLog, err := os.ReadFile(debugLogPath)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.Contains(t, string(debugLog), "Debug = false")
+	assert.Contains(t, string(debugLog), "[test-1] Debug log from stage")
+	assert.Contains(t, string(debugLog), "[test-1] Test passed.")
+	assert.NotContains(t, string(debugLog), "\x1b[")
+
+	// Failing to create the debug log is reported in the output format
+	var output bytes.Buffer
+	logger.SetDefaultWriter(&output)
+	defer logger.SetDefaultWriter(nil)
+	defer logger.SetDefaultOutputFormat(logger.TextOutputFormat)
+
+	env["CODECRAFTERS_DEBUG_LOG_PATH"] = filepath.Join(t.TempDir(), "missing", "debug.log")
+	env["CODECRAFTERS_LOG_FORMAT"] = "json"
+
+	exitCode = RunCLI(env, definition)
+	assert.Equal(t, 1, exitCode)
+
+	entry := map[string]any{}
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &entry))
+	assert.Equal(t, "error", entry["level"])
+	assert.Contains(t, entry["message"], "CodeCrafters internal error. Error creating debug log")
+}
+
+func TestContextErrorInJSONMode(t *testing.T) {
+	var output bytes.Buffer
+	logger.SetDefaultWriter(&output)
+	defer logger.SetDefaultWriter(nil)
+	defer logger.SetDefaultOutputFormat(logger.TextOutputFormat)
+
+	env := map[string]string{
+		"CODECRAFTERS_TEST_CASES_JSON": buildTestCasesJson([]string{"test-1"}),
+		"CODECRAFTERS_LOG_FORMAT":      "json",
+	}
+
+	exitCode := RunCLI(env, tester_definition.TesterDefinition{})
+	assert.Equal(t, 1, exitCode)
+
+	entry := map[string]any{}
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &entry))
+	assert.Equal(t, "error", entry["level"])
+	assert.Contains(t, entry["message"], "CODECRAFTERS_REPOSITORY_DIR")
+}
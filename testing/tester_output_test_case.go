@@ -8,8 +8,10 @@ import (
 	"testing"
 
 	tester_utils "github.com/debanandanayak/tester-utils"
+	"github.com/debanandanayak/tester-utils/logger"
 	"github.com/debanandanayak/tester-utils/stdio_mocker"
 	"github.com/debanandanayak/tester-utils/tester_definition"
+	"github.com/fatih/color"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -127,6 +129,11 @@ func runCLIStage(testerDefinition tester_definition.TesterDefinition, testCasesJ
 		panic(err)
 	}
 
+	// Output is captured in a file, so colors would be disabled. Fixtures are recorded with colors. fatih/color's global
+	// setting is overridden too, for testers that use it directly (like bytes_diff_visualizer).
+	logger.SetDefaultColorPolicy(logger.AlwaysColorPolicy)
+	color.NoColor = false
+
 	return tester_utils.RunCLI(map[string]string{
 		"CODECRAFTERS_TEST_CASES_JSON": testCasesJson,
 		"CODECRAFTERS_REPOSITORY_DIR":  path,